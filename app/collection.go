@@ -6,21 +6,91 @@ import (
 	"time"
 )
 
+type Visibility string
+
+const (
+	// VisibilityPublic collections can be read by anyone and are listed on
+	// their author's profile.
+	VisibilityPublic Visibility = "public"
+	// VisibilityUnlisted collections can be read by anyone who knows their
+	// id but are not listed.
+	VisibilityUnlisted Visibility = "unlisted"
+	VisibilityPrivate  Visibility = "private"
+)
+
 type Collection struct {
-	ID          int       `json:"id" db:"id"`
-	AuthorID    int       `json:"author" db:"author_id"`
-	Name        string    `json:"name" db:"name"`
-	Description string    `json:"description,omitempty" db:"description"`
-	Poster      string    `json:"poster,omitempty" db:"poster"`
-	Images      []*Image  `json:"images,omitempty"`
-	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	ID          int        `json:"id" db:"id"`
+	AuthorID    int        `json:"author" db:"author_id"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description,omitempty" db:"description"`
+	Poster      string     `json:"poster,omitempty" db:"poster"`
+	Visibility  Visibility `json:"visibility" db:"visibility"`
+	ImageCount  int        `json:"imageCount" db:"image_count"`
+	ForkedFrom  *int       `json:"forkedFrom,omitempty" db:"forked_from"`
+	ForkCount   int        `json:"forkCount" db:"fork_count"`
+	// Version is bumped by every change to the collection or its images.
+	Version   int        `json:"version" db:"version"`
+	Images    []*Image   `json:"images,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time  `json:"updatedAt" db:"updated_at"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" db:"deleted_at"`
 }
 
 type Image struct {
-	Path         string    `json:"image" db:"img_path"`
-	CollectionId int       `json:"collectionId" db:"collection_id"`
-	SavedAt      time.Time `json:"savedAt" db:"created_at"`
+	Path          string    `json:"image" db:"img_path"`
+	CollectionId  int       `json:"collectionId" db:"collection_id"`
+	Position      int       `json:"position" db:"position"`
+	Caption       string    `json:"caption,omitempty" db:"caption"`
+	AltText       string    `json:"altText,omitempty" db:"alt_text"`
+	SourceURL     string    `json:"sourceUrl,omitempty" db:"source_url"`
+	Attribution   string    `json:"attribution,omitempty" db:"attribution"`
+	Width         int       `json:"width,omitempty" db:"width"`
+	Height        int       `json:"height,omitempty" db:"height"`
+	DominantColor string    `json:"dominantColor,omitempty" db:"dominant_color"`
+	Tags          []string  `json:"tags,omitempty"`
+	SavedAt       time.Time `json:"savedAt" db:"created_at"`
+}
+
+type ImagePatch struct {
+	Caption       *string `json:"caption"`
+	AltText       *string `json:"altText"`
+	SourceURL     *string `json:"sourceUrl"`
+	Attribution   *string `json:"attribution"`
+	Width         *int    `json:"width"`
+	Height        *int    `json:"height"`
+	DominantColor *string `json:"dominantColor"`
+}
+
+// Apply copies every field set in patch onto the image.
+func (i *Image) Apply(patch ImagePatch) {
+	if v := patch.Caption; v != nil {
+		i.Caption = *v
+	}
+	if v := patch.AltText; v != nil {
+		i.AltText = *v
+	}
+	if v := patch.SourceURL; v != nil {
+		i.SourceURL = *v
+	}
+	if v := patch.Attribution; v != nil {
+		i.Attribution = *v
+	}
+	if v := patch.Width; v != nil {
+		i.Width = *v
+	}
+	if v := patch.Height; v != nil {
+		i.Height = *v
+	}
+	if v := patch.DominantColor; v != nil {
+		i.DominantColor = *v
+	}
+}
+
+// IsReadableByAnyone reports whether the collection can be read without
+// being its author.
+func (c *Collection) IsReadableByAnyone() bool {
+	return c.Visibility == VisibilityPublic || c.Visibility == VisibilityUnlisted
 }
 
 func (c *Collection) Collection() *Collection {
@@ -47,7 +117,7 @@ func isSaved(collection *Collection, imgPath *string) bool {
 		collection = collection.CollectionWithImages()
 	}
 	for _, i := range collection.Images {
-		if imgPath == &i.Path {
+		if *imgPath == i.Path {
 			return true
 		}
 	}
@@ -55,26 +125,49 @@ func isSaved(collection *Collection, imgPath *string) bool {
 }
 
 type CollectionFilter struct {
-	ID       *int
-	Name     *string
-	AuthorId *int
+	ID         *int
+	Name       *string
+	AuthorId   *int
+	Visibility *Visibility
+	// MemberID matches collections the user authored or is a member of.
+	MemberID *int
+	// Tags matches collections tagged with all or any of the tags,
+	// depending on TagMatch.
+	Tags     []string
+	TagMatch TagMatch
+	// Deleted matches collections in the trash instead of live ones.
+	Deleted bool
+
+	// IncludeImages eager-loads the images of every matched collection.
+	IncludeImages bool
+
+	// Sort and Order default to the oldest collections first. After and
+	// Before page through the listing from a cursor in the same sort.
+	Sort   CollectionSort
+	Order  SortOrder
+	After  *Cursor
+	Before *Cursor
 
 	Limit  int
 	Offset int
 }
 
 type CollectionPatch struct {
-	Name        *string `json:"name"`
-	Description *string `json:"description"`
-	Poster      *string `json:"poster"`
+	Name        *string     `json:"name"`
+	Description *string     `json:"description"`
+	Poster      *string     `json:"poster"`
+	Visibility  *Visibility `json:"visibility"`
 }
 
-func (c *Collection) SaveImageToCollection(imgPath *string) (*Collection, error) {
-	if !isSaved(c, imgPath) {
-		c.Images = append(c.Images, &Image{
-			Path:         *imgPath,
-			CollectionId: c.ID,
-		})
+func (c *Collection) SaveImageToCollection(image *Image) (*Collection, error) {
+	if !isSaved(c, &image.Path) {
+		image.CollectionId = c.ID
+		image.Position = 0
+		if n := len(c.Images); n > 0 {
+			image.Position = c.Images[n-1].Position + 1
+		}
+
+		c.Images = append(c.Images, image)
 		return c, nil
 	}
 
@@ -84,7 +177,7 @@ func (c *Collection) SaveImageToCollection(imgPath *string) (*Collection, error)
 func (c *Collection) DeleteImageFromCollection(imgPath string) (*Collection, error) {
 	for i, image := range c.Images {
 		if imgPath == image.Path {
-			c.Images = slices.Delete(c.Images, i, i)
+			c.Images = slices.Delete(c.Images, i, i+1)
 			return c, nil
 		}
 	}
@@ -92,18 +185,113 @@ func (c *Collection) DeleteImageFromCollection(imgPath string) (*Collection, err
 	return nil, ErrImageNotSaved
 }
 
+type BatchImageStatus string
+
+const (
+	BatchImageAdded          BatchImageStatus = "added"
+	BatchImageAlreadyPresent BatchImageStatus = "already_present"
+	BatchImageRemoved        BatchImageStatus = "removed"
+	BatchImageNotFound       BatchImageStatus = "not_found"
+)
+
+// ImageBatch lists the images to save to and remove from a collection in
+// a single change. Removals are applied first.
+type ImageBatch struct {
+	Add    []*Image
+	Remove []string
+}
+
+type BatchImageResult struct {
+	Path   string           `json:"image"`
+	Status BatchImageStatus `json:"status"`
+}
+
+// ImageTransfer copies images from one collection to others, removing them
+// from the source when Move is set.
+type ImageTransfer struct {
+	SourceID  int
+	TargetIDs []int
+	Paths     []string
+	Move      bool
+	// SourceVersion is checked when moving, as for the other changes.
+	SourceVersion *int
+}
+
+type ImageTransferResult struct {
+	CollectionID int              `json:"collectionId"`
+	Path         string           `json:"image"`
+	Status       BatchImageStatus `json:"status"`
+}
+
+// Methods that change a collection take the version the caller last saw,
+// or nil to skip the check. They return ErrNotFound when the collection is
+// gone and ErrVersionMismatch when it changed since that version.
 type CollectionService interface {
 	CreateCollection(context.Context, *Collection) error
 
 	CollectionByID(context.Context, int) (*Collection, error)
 
-	Collections(context.Context, CollectionFilter) ([]*Collection, error)
+	Collections(context.Context, CollectionFilter) (*CollectionPage, error)
+
+	UpdateCollection(ctx context.Context, collection *Collection, patch CollectionPatch, version *int) error
+
+	// DeleteCollection moves the collection to the trash.
+	DeleteCollection(ctx context.Context, id int, version *int) error
+
+	// DeletedCollectionByID returns a collection in the trash, or
+	// ErrNotFound.
+	DeletedCollectionByID(context.Context, int) (*Collection, error)
+
+	// RestoreCollection takes the collection out of the trash. It returns
+	// ErrDuplicateName if a live collection of the author took its name.
+	RestoreCollection(ctx context.Context, id int, version *int) error
+
+	// PurgeCollection deletes the collection and its images for good,
+	// whether it is in the trash or not.
+	PurgeCollection(ctx context.Context, id int, version *int) error
+
+	// PurgeDeletedCollections permanently deletes the collections trashed
+	// before the given time and returns how many there were.
+	PurgeDeletedCollections(ctx context.Context, before time.Time) (int64, error)
+
+	// ForkCollection copies the collection, its images and tags into a new
+	// private collection of the author. The name gets a numbered suffix when
+	// the author already has a collection with the same name.
+	ForkCollection(ctx context.Context, sourceID int, authorID int) (*Collection, error)
+
+	SaveImageToCollection(ctx context.Context, collectionID int, image *Image, version *int) error
+
+	DeleteImageFromCollection(ctx context.Context, collectionID int, path string, version *int) error
+
+	// BatchUpdateImages applies the batch atomically and reports what
+	// happened to every image, additions first.
+	BatchUpdateImages(ctx context.Context, collectionID int, batch ImageBatch, version *int) ([]*BatchImageResult, error)
+
+	// TransferImages copies or moves images in a single transaction,
+	// keeping their metadata and tags. Moved images keep the time they were
+	// first saved.
+	TransferImages(context.Context, ImageTransfer) ([]*ImageTransferResult, error)
+
+	// UpdateImage applies patch to the metadata of a saved image and
+	// returns it, or ErrNotFound if the image is not in the collection.
+	UpdateImage(ctx context.Context, collectionID int, path string, patch ImagePatch, version *int) (*Image, error)
+
+	// ReorderImages sets the order of the collection's images to paths,
+	// which must list every image exactly once. Otherwise it returns
+	// ErrStaleImageOrder.
+	ReorderImages(ctx context.Context, collectionID int, paths []string, version *int) error
+
+	ShareLinkService
+
+	CollectionMemberService
+
+	CollectionTagService
 
-	UpdateCollection(context.Context, *Collection, CollectionPatch) error
+	CollectionSearchService
 
-	DeleteCollection(context.Context, int) error
+	CollectionRevisionService
 
-	SaveImageToCollection(context.Context, int, string) error
+	CollectionSyncService
 
-	DeleteImageFromCollection(context.Context, int, string) error
+	CollectionImportService
 }
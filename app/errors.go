@@ -9,4 +9,12 @@ var (
 	ErrInternal          = errors.New("internal error")
 	ErrImageAlreadySaved = errors.New("image already in collection")
 	ErrImageNotSaved     = errors.New("image not in collection")
+	ErrTokenExpired      = errors.New("token expired")
+	ErrTokenReused       = errors.New("token reused")
+	ErrDuplicateMember   = errors.New("user is already a member")
+	ErrStaleImageOrder   = errors.New("image order does not match collection")
+	ErrInvalidTag        = errors.New("invalid tag")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrDuplicateName     = errors.New("duplicate collection name")
+	ErrVersionMismatch   = errors.New("collection version does not match")
 )
@@ -0,0 +1,82 @@
+package app
+
+import "time"
+
+// ExportVersion is the version of the export format. It changes whenever a
+// field is removed or changes meaning, so imports can tell old files apart.
+const ExportVersion = 1
+
+// Export is the portable form of a user's collections. It leaves out ids
+// and counts, which only make sense on the server that made it.
+type Export struct {
+	Version     int                 `json:"version"`
+	ExportedAt  time.Time           `json:"exportedAt"`
+	Collections []*ExportCollection `json:"collections"`
+}
+
+type ExportCollection struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Poster      string         `json:"poster,omitempty"`
+	Visibility  Visibility     `json:"visibility"`
+	Tags        []string       `json:"tags,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	Images      []*ExportImage `json:"images"`
+}
+
+// ExportImage is a saved image. Images are listed in display order.
+type ExportImage struct {
+	Path          string    `json:"image"`
+	Caption       string    `json:"caption,omitempty"`
+	AltText       string    `json:"altText,omitempty"`
+	SourceURL     string    `json:"sourceUrl,omitempty"`
+	Attribution   string    `json:"attribution,omitempty"`
+	Width         int       `json:"width,omitempty"`
+	Height        int       `json:"height,omitempty"`
+	DominantColor string    `json:"dominantColor,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	SavedAt       time.Time `json:"savedAt"`
+	// File is where an archive keeps the image file, if it has one.
+	File string `json:"file,omitempty"`
+}
+
+// NewExport converts collections, loaded with their images, to the export
+// format.
+func NewExport(collections []*Collection, exportedAt time.Time) *Export {
+	export := &Export{
+		Version:     ExportVersion,
+		ExportedAt:  exportedAt,
+		Collections: make([]*ExportCollection, 0, len(collections)),
+	}
+
+	for _, c := range collections {
+		ec := &ExportCollection{
+			Name:        c.Name,
+			Description: c.Description,
+			Poster:      c.Poster,
+			Visibility:  c.Visibility,
+			Tags:        c.Tags,
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+			Images:      make([]*ExportImage, 0, len(c.Images)),
+		}
+		for _, image := range c.Images {
+			ec.Images = append(ec.Images, &ExportImage{
+				Path:          image.Path,
+				Caption:       image.Caption,
+				AltText:       image.AltText,
+				SourceURL:     image.SourceURL,
+				Attribution:   image.Attribution,
+				Width:         image.Width,
+				Height:        image.Height,
+				DominantColor: image.DominantColor,
+				Tags:          image.Tags,
+				SavedAt:       image.SavedAt,
+			})
+		}
+		export.Collections = append(export.Collections, ec)
+	}
+
+	return export
+}
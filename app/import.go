@@ -0,0 +1,65 @@
+package app
+
+import "context"
+
+// ImportStrategy decides what happens to an imported collection whose name
+// the author already uses.
+type ImportStrategy string
+
+const (
+	// ImportRename imports the collection under the lowest free " (n)"
+	// suffix of its name.
+	ImportRename ImportStrategy = "rename"
+	// ImportSkip leaves the existing collection alone.
+	ImportSkip ImportStrategy = "skip"
+	// ImportMerge adds the images and tags the existing collection is
+	// missing. Its other details and saved images are kept.
+	ImportMerge ImportStrategy = "merge"
+)
+
+func (s ImportStrategy) IsValid() bool {
+	switch s {
+	case ImportRename, ImportSkip, ImportMerge:
+		return true
+	}
+	return false
+}
+
+type ImportStatus string
+
+const (
+	ImportCreated ImportStatus = "created"
+	ImportRenamed ImportStatus = "renamed"
+	ImportSkipped ImportStatus = "skipped"
+	ImportMerged  ImportStatus = "merged"
+)
+
+// CollectionImport recreates collections, with their images and tags,
+// under an author.
+type CollectionImport struct {
+	AuthorID    int
+	Collections []*Collection
+	Strategy    ImportStrategy
+	// DryRun reports what the import would do without keeping any of it.
+	DryRun bool
+}
+
+type ImportResult struct {
+	Name   string       `json:"name"`
+	Status ImportStatus `json:"status"`
+	// ImportedAs is the name the collection got, or was merged into.
+	ImportedAs string `json:"importedAs,omitempty"`
+	// CollectionID is left out of dry runs.
+	CollectionID  *int `json:"collectionId,omitempty"`
+	ImagesAdded   int  `json:"imagesAdded"`
+	ImagesSkipped int  `json:"imagesSkipped"`
+	// ImagePaths are the paths of the imported images the collection holds
+	// afterwards, whether they were added or already saved.
+	ImagePaths []string `json:"-"`
+}
+
+type CollectionImportService interface {
+	// ImportCollections imports every collection or none of them, and
+	// reports what happened to each in order.
+	ImportCollections(context.Context, CollectionImport) ([]*ImportResult, error)
+}
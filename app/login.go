@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// LockoutPolicy decides how long a login key is locked after repeated
+// failures. Once Threshold failures have piled up within Window, every
+// further failure doubles the lock, starting at BaseDelay and capped at
+// MaxDelay.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+type LoginAttemptService interface {
+	// LockedUntil returns the latest lock expiry across keys, or the zero
+	// time when none of them is locked.
+	LockedUntil(ctx context.Context, keys ...string) (time.Time, error)
+
+	// RecordLoginFailure counts a failed login for key and returns the time
+	// it is locked until, if the policy locks it.
+	RecordLoginFailure(ctx context.Context, key string, policy LockoutPolicy) (time.Time, error)
+
+	ResetLoginFailures(ctx context.Context, key string) error
+}
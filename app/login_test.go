@@ -0,0 +1,35 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockDuration(t *testing.T) {
+	policy := LockoutPolicy{
+		Threshold: 5,
+		BaseDelay: time.Minute,
+		MaxDelay:  10 * time.Minute,
+		Window:    time.Hour,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{8, 8 * time.Minute},
+		{9, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := policy.LockDuration(tt.failures); got != tt.want {
+			t.Errorf("LockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
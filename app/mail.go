@@ -0,0 +1,13 @@
+package app
+
+import "context"
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(context.Context, Mail) error
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleNone   Role = ""
+	RoleViewer Role = "viewer"
+	// RoleEditor can also save, remove and arrange images.
+	RoleEditor Role = "editor"
+	// RoleOwner can do everything the author can, including deleting the
+	// collection and managing its members.
+	RoleOwner Role = "owner"
+)
+
+type Action int
+
+const (
+	ActionView Action = iota
+	ActionEditImages
+	ActionUpdate
+	ActionDelete
+	ActionManageMembers
+)
+
+func (r Role) Can(action Action) bool {
+	switch r {
+	case RoleOwner:
+		return true
+	case RoleEditor:
+		return action == ActionView || action == ActionEditImages
+	case RoleViewer:
+		return action == ActionView
+	default:
+		return false
+	}
+}
+
+type CollectionMember struct {
+	CollectionID int       `json:"collectionId" db:"collection_id"`
+	UserID       int       `json:"userId" db:"user_id"`
+	Email        string    `json:"email,omitempty" db:"email"`
+	Role         Role      `json:"role" db:"role"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CollectionMemberService interface {
+	CollectionMembers(ctx context.Context, collectionID int) ([]*CollectionMember, error)
+
+	// CollectionMember returns the membership of the user in the
+	// collection, or ErrNotFound.
+	CollectionMember(ctx context.Context, collectionID int, userID int) (*CollectionMember, error)
+
+	// AddCollectionMember returns ErrDuplicateMember when the user already
+	// belongs to the collection.
+	AddCollectionMember(context.Context, *CollectionMember) error
+
+	UpdateCollectionMember(context.Context, *CollectionMember) error
+
+	RemoveCollectionMember(ctx context.Context, collectionID int, userID int) error
+}
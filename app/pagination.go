@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+type CollectionSort string
+
+const (
+	SortByName    CollectionSort = "name"
+	SortByCreated CollectionSort = "created"
+	SortByUpdated CollectionSort = "updated"
+	SortByImages  CollectionSort = "images"
+)
+
+func (s CollectionSort) IsValid() bool {
+	switch s {
+	case SortByName, SortByCreated, SortByUpdated, SortByImages:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cursor marks a position in a sorted listing: the sort value of a row and
+// its id to break ties. It is handed to clients as an opaque string.
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Order SortOrder `json:"o"`
+	Value string    `json:"v"`
+	ID    int       `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by Encode, returning
+// ErrInvalidCursor when it is malformed or its value does not fit its sort.
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	switch CollectionSort(cursor.Sort) {
+	case SortByName:
+	case SortByCreated, SortByUpdated:
+		_, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case SortByImages:
+		_, err = strconv.Atoi(cursor.Value)
+	default:
+		err = ErrInvalidCursor
+	}
+
+	if err != nil || (cursor.Order != SortAsc && cursor.Order != SortDesc) {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// CollectionCursor returns the cursor pointing at the collection in a
+// listing sorted by sort in order.
+func CollectionCursor(c *Collection, sort CollectionSort, order SortOrder) *Cursor {
+	cursor := &Cursor{Sort: string(sort), Order: order, ID: c.ID}
+
+	switch sort {
+	case SortByName:
+		cursor.Value = c.Name
+	case SortByCreated:
+		cursor.Value = c.CreatedAt.Format(time.RFC3339Nano)
+	case SortByUpdated:
+		cursor.Value = c.UpdatedAt.Format(time.RFC3339Nano)
+	case SortByImages:
+		cursor.Value = strconv.Itoa(c.ImageCount)
+	}
+
+	return cursor
+}
+
+// CollectionPage is one page of a collection listing. Next and Prev are nil
+// at either end of the listing.
+type CollectionPage struct {
+	Collections []*Collection
+	Total       int
+	Next        *Cursor
+	Prev        *Cursor
+}
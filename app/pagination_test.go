@@ -0,0 +1,53 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name  string
+		in    string
+		valid bool
+	}{
+		{"name", Cursor{Sort: "name", Order: SortAsc, Value: "Film", ID: 4}.Encode(), true},
+		{"created", Cursor{Sort: "created", Order: SortDesc, Value: "2024-05-01T10:00:00.5Z", ID: 4}.Encode(), true},
+		{"updated", Cursor{Sort: "updated", Order: SortAsc, Value: "2024-05-01T10:00:00Z", ID: 4}.Encode(), true},
+		{"images", Cursor{Sort: "images", Order: SortDesc, Value: "12", ID: 4}.Encode(), true},
+		{"empty", "", false},
+		{"not base64", "!!!", false},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"name","o":"asc","v":"a","id":1}`)), false},
+		{"not json", encode("posters"), false},
+		{"wrong json type", encode(`{"s":"name","o":"asc","v":"a","id":"1"}`), false},
+		{"unknown sort", Cursor{Sort: "author", Order: SortAsc, Value: "a", ID: 1}.Encode(), false},
+		{"missing sort", encode(`{"o":"asc","v":"a","id":1}`), false},
+		{"bad time", Cursor{Sort: "created", Order: SortAsc, Value: "yesterday", ID: 1}.Encode(), false},
+		{"bad count", Cursor{Sort: "images", Order: SortAsc, Value: "1; DROP TABLE collections", ID: 1}.Encode(), false},
+		{"bad order", Cursor{Sort: "name", Order: "sideways", Value: "a", ID: 1}.Encode(), false},
+		{"missing order", encode(`{"s":"name","v":"a","id":1}`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(tt.in)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidCursor) || cursor != nil {
+					t.Fatalf("DecodeCursor(%q) = %v, %v, want ErrInvalidCursor", tt.in, cursor, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q) returned %v", tt.in, err)
+			}
+			if got := cursor.Encode(); got != tt.in {
+				t.Errorf("round trip = %q, want %q", got, tt.in)
+			}
+		})
+	}
+}
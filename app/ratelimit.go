@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// RateLimit allows bursts of up to Limit requests, refilled evenly over
+// Period.
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (rl RateLimit) refillRate() float64 {
+	return float64(rl.Limit) / rl.Period.Seconds()
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request would be allowed.
+	RetryAfter time.Duration
+}
+
+// TokenBucket is the persisted state of a single rate limit key.
+type TokenBucket struct {
+	Tokens    float64   `db:"tokens"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func NewTokenBucket(rl RateLimit, now time.Time) *TokenBucket {
+	return &TokenBucket{Tokens: float64(rl.Limit), UpdatedAt: now}
+}
+
+// Take refills the bucket for the time elapsed since it was last updated
+// and consumes a token if one is available.
+func (b *TokenBucket) Take(rl RateLimit, now time.Time) RateLimitResult {
+	rate := rl.refillRate()
+
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(rl.Limit), b.Tokens+elapsed*rate)
+	}
+	b.UpdatedAt = now
+
+	result := RateLimitResult{Limit: rl.Limit}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.Tokens) / rate)
+	}
+
+	result.Remaining = int(math.Floor(b.Tokens))
+	result.ResetAfter = secondsToDuration((float64(rl.Limit) - b.Tokens) / rate)
+
+	return result
+}
+
+// IsFull reports whether the bucket would be full at now, in which case it
+// can be forgotten without changing any future decision.
+func (b *TokenBucket) IsFull(rl RateLimit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.UpdatedAt).Seconds()*rl.refillRate() >= float64(rl.Limit)
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, rl RateLimit) (RateLimitResult, error)
+}
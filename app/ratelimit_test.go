@@ -0,0 +1,106 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	limit := RateLimit{Limit: 4, Period: 4 * time.Second}
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		tokens float64
+		after  time.Duration
+		want   RateLimitResult
+		left   float64
+	}{
+		{
+			name:   "full",
+			tokens: 4,
+			want:   RateLimitResult{Allowed: true, Limit: 4, Remaining: 3, ResetAfter: time.Second},
+			left:   3,
+		},
+		{
+			name:   "last token",
+			tokens: 1,
+			want:   RateLimitResult{Allowed: true, Limit: 4, Remaining: 0, ResetAfter: 4 * time.Second},
+			left:   0,
+		},
+		{
+			name:   "empty",
+			tokens: 0,
+			want:   RateLimitResult{Limit: 4, Remaining: 0, ResetAfter: 4 * time.Second, RetryAfter: time.Second},
+			left:   0,
+		},
+		{
+			name:   "partly refilled",
+			tokens: 0,
+			after:  500 * time.Millisecond,
+			want:   RateLimitResult{Limit: 4, Remaining: 0, ResetAfter: 3500 * time.Millisecond, RetryAfter: 500 * time.Millisecond},
+			left:   0.5,
+		},
+		{
+			name:   "refilled",
+			tokens: 0,
+			after:  time.Second,
+			want:   RateLimitResult{Allowed: true, Limit: 4, Remaining: 0, ResetAfter: 4 * time.Second},
+			left:   0,
+		},
+		{
+			name:   "refill capped at limit",
+			tokens: 2,
+			after:  time.Hour,
+			want:   RateLimitResult{Allowed: true, Limit: 4, Remaining: 3, ResetAfter: time.Second},
+			left:   3,
+		},
+		{
+			name:   "clock went back",
+			tokens: 0,
+			after:  -time.Second,
+			want:   RateLimitResult{Limit: 4, Remaining: 0, ResetAfter: 4 * time.Second, RetryAfter: time.Second},
+			left:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &TokenBucket{Tokens: tt.tokens, UpdatedAt: start}
+			now := start.Add(tt.after)
+
+			if got := b.Take(limit, now); got != tt.want {
+				t.Errorf("Take = %+v, want %+v", got, tt.want)
+			}
+			if b.Tokens != tt.left {
+				t.Errorf("tokens = %v, want %v", b.Tokens, tt.left)
+			}
+			if !b.UpdatedAt.Equal(now) {
+				t.Errorf("updated at %v, want %v", b.UpdatedAt, now)
+			}
+		})
+	}
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	limit := RateLimit{Limit: 3, Period: time.Minute}
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	b := NewTokenBucket(limit, now)
+
+	for i := 2; i >= 0; i-- {
+		if got := b.Take(limit, now); !got.Allowed || got.Remaining != i {
+			t.Fatalf("Take = %+v, want allowed with %d remaining", got, i)
+		}
+	}
+
+	if got := b.Take(limit, now); got.Allowed || got.RetryAfter != 20*time.Second {
+		t.Fatalf("Take = %+v, want denied for 20s", got)
+	}
+
+	if b.IsFull(limit, now.Add(59*time.Second)) {
+		t.Error("bucket full before the period passed")
+	}
+	if !b.IsFull(limit, now.Add(time.Minute)) {
+		t.Error("bucket not full after the period passed")
+	}
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+type RevisionAction string
+
+const (
+	RevisionCreate      RevisionAction = "create"
+	RevisionFork        RevisionAction = "fork"
+	RevisionUpdate      RevisionAction = "update"
+	RevisionSaveImage   RevisionAction = "save_image"
+	RevisionDeleteImage RevisionAction = "delete_image"
+	RevisionUpdateImage RevisionAction = "update_image"
+	RevisionBatchImages RevisionAction = "batch_images"
+	RevisionReorder     RevisionAction = "reorder_images"
+	RevisionTransferIn  RevisionAction = "receive_images"
+	RevisionTransferOut RevisionAction = "move_images"
+	RevisionRestore     RevisionAction = "restore"
+	RevisionImport      RevisionAction = "import"
+)
+
+// CollectionSnapshot is the state of a collection as recorded by a
+// revision.
+type CollectionSnapshot struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Poster      string     `json:"poster"`
+	Visibility  Visibility `json:"visibility"`
+	Tags        []string   `json:"tags"`
+	Images      []*Image   `json:"images"`
+}
+
+func (c *Collection) Snapshot() *CollectionSnapshot {
+	snapshot := &CollectionSnapshot{
+		Name:        c.Name,
+		Description: c.Description,
+		Poster:      c.Poster,
+		Visibility:  c.Visibility,
+		Tags:        c.Tags,
+		Images:      c.Images,
+	}
+
+	if snapshot.Tags == nil {
+		snapshot.Tags = []string{}
+	}
+	if snapshot.Images == nil {
+		snapshot.Images = []*Image{}
+	}
+
+	return snapshot
+}
+
+type CollectionRevision struct {
+	CollectionID int                 `json:"collectionId"`
+	Revision     int                 `json:"revision"`
+	ActorID      *int                `json:"actorId"`
+	Action       RevisionAction      `json:"action"`
+	Snapshot     *CollectionSnapshot `json:"snapshot,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt"`
+}
+
+type CollectionRevisionService interface {
+	// CollectionRevisions lists the revisions of a collection, newest
+	// first, without their snapshots.
+	CollectionRevisions(ctx context.Context, collectionID int, limit int, offset int) ([]*CollectionRevision, error)
+
+	// CollectionRevision returns a revision with its snapshot, or
+	// ErrNotFound.
+	CollectionRevision(ctx context.Context, collectionID int, revision int) (*CollectionRevision, error)
+
+	// RestoreRevision puts the collection back in the state of the
+	// revision, which is recorded as a new revision.
+	RestoreRevision(ctx context.Context, collectionID int, revision int, version *int) error
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that attributes the changes made with it
+// to the user.
+func WithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorFromContext returns the user set by WithActor, or nil.
+func ActorFromContext(ctx context.Context) *int {
+	if userID, ok := ctx.Value(actorKey{}).(int); ok {
+		return &userID
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package app
+
+import "context"
+
+type SearchQuery struct {
+	// Query uses web search syntax: quoted phrases, "or" and -exclusions.
+	Query string
+	// UserID sees their own and shared collections besides public ones.
+	// Anonymous searches leave it nil.
+	UserID *int
+
+	Limit  int
+	Offset int
+}
+
+// SearchHighlights are HTML: the text is escaped and matches are wrapped in
+// <b> tags.
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ImageMatch is an image whose caption matched the query. The caption is
+// highlighted as in SearchHighlights.
+type ImageMatch struct {
+	Path    string `json:"image" db:"img_path"`
+	Caption string `json:"caption" db:"caption"`
+}
+
+type SearchResult struct {
+	Collection *Collection      `json:"collection"`
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `json:"highlights"`
+	Images     []*ImageMatch    `json:"images,omitempty"`
+}
+
+type CollectionSearchService interface {
+	// SearchCollections returns a page of matching collections, best match
+	// first, and the total number of matches.
+	SearchCollections(context.Context, SearchQuery) ([]*SearchResult, int, error)
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+type SharePermission string
+
+const (
+	SharePermissionRead SharePermission = "read"
+	// SharePermissionEdit also lets signed in holders of the link save and
+	// remove images.
+	SharePermissionEdit SharePermission = "edit"
+)
+
+type ShareLink struct {
+	ID           int `json:"id" db:"id"`
+	CollectionID int `json:"collectionId" db:"collection_id"`
+	// Token is only known right after the link is created; afterwards only
+	// its hash is stored.
+	Token      string          `json:"token,omitempty"`
+	TokenHash  string          `json:"-" db:"token_hash"`
+	Permission SharePermission `json:"permission" db:"permission"`
+	ExpiresAt  *time.Time      `json:"expiresAt,omitempty" db:"expires_at"`
+	RevokedAt  *time.Time      `json:"revokedAt,omitempty" db:"revoked_at"`
+	CreatedBy  int             `json:"createdBy" db:"created_by"`
+	CreatedAt  time.Time       `json:"createdAt" db:"created_at"`
+}
+
+func NewShareLink(collectionID int, createdBy int, permission SharePermission, expiresAt *time.Time) (*ShareLink, error) {
+	token, hash, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShareLink{
+		CollectionID: collectionID,
+		Token:        token,
+		TokenHash:    hash,
+		Permission:   permission,
+		ExpiresAt:    expiresAt,
+		CreatedBy:    createdBy,
+	}, nil
+}
+
+func (l *ShareLink) CanEdit() bool {
+	return l.Permission == SharePermissionEdit
+}
+
+type ShareLinkService interface {
+	CreateShareLink(context.Context, *ShareLink) error
+
+	ShareLinks(ctx context.Context, collectionID int) ([]*ShareLink, error)
+
+	// RevokeShareLink revokes the link with the given id if it belongs to
+	// the collection, or returns ErrNotFound.
+	RevokeShareLink(ctx context.Context, collectionID int, id int) error
+
+	// CollectionByShareToken resolves an unrevoked, unexpired share token
+	// to its collection, or returns ErrNotFound.
+	CollectionByShareToken(ctx context.Context, token string) (*Collection, *ShareLink, error)
+}
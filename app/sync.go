@@ -0,0 +1,77 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// ImageTombstone marks an image removed from a collection.
+type ImageTombstone struct {
+	CollectionID int    `json:"collectionId"`
+	Path         string `json:"image"`
+}
+
+// SyncCursor marks a change: the transaction that made it and its sequence
+// number. Changes are synced in cursor order. It is handed to clients as an
+// opaque string.
+type SyncCursor struct {
+	XID uint64 `json:"x" db:"sync_xid"`
+	Seq int64  `json:"s" db:"sync_seq"`
+}
+
+func (c SyncCursor) Compare(other SyncCursor) int {
+	if n := cmp.Compare(c.XID, other.XID); n != 0 {
+		return n
+	}
+	return cmp.Compare(c.Seq, other.Seq)
+}
+
+func (c SyncCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSyncCursor parses a cursor produced by Encode, returning
+// ErrInvalidCursor when it is malformed.
+func DecodeSyncCursor(s string) (SyncCursor, error) {
+	cursor := SyncCursor{}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return SyncCursor{}, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// SyncChanges lists what changed after a cursor in the collections a user
+// authors or is a member of. Collections and images are their current
+// state, so clients apply the deletions first and then the rest.
+// Collections do not carry their images; those changed are listed in
+// Images. Collections in the trash, and those the user stopped being a
+// member of, are listed as deleted. Restored collections come back with all
+// their images.
+type SyncChanges struct {
+	Collections        []*Collection     `json:"collections"`
+	Images             []*Image          `json:"images"`
+	DeletedCollections []int             `json:"deletedCollections"`
+	DeletedImages      []*ImageTombstone `json:"deletedImages"`
+	// Cursor is passed as since to get the changes after these.
+	Cursor string `json:"cursor"`
+	// HasMore is set when the limit cut the changes short.
+	HasMore bool `json:"hasMore"`
+}
+
+type CollectionSyncService interface {
+	// CollectionChanges returns up to limit changes to the collections of
+	// the user after the since cursor, oldest first. A zero cursor returns
+	// everything. Changes of transactions that may still be followed by
+	// earlier ones committing are left for a later sync.
+	CollectionChanges(ctx context.Context, userID int, since SyncCursor, limit int) (*SyncChanges, error)
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDecodeSyncCursor(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name  string
+		in    string
+		want  SyncCursor
+		valid bool
+	}{
+		{"zero", SyncCursor{}.Encode(), SyncCursor{}, true},
+		{"cursor", SyncCursor{XID: 812, Seq: 40}.Encode(), SyncCursor{XID: 812, Seq: 40}, true},
+		{"largest", SyncCursor{XID: math.MaxUint64, Seq: math.MaxInt64}.Encode(), SyncCursor{XID: math.MaxUint64, Seq: math.MaxInt64}, true},
+		{"not base64", "!!!", SyncCursor{}, false},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"x":1,"s":1}`)), SyncCursor{}, false},
+		{"not json", encode("posters"), SyncCursor{}, false},
+		{"negative xid", encode(`{"x":-1,"s":1}`), SyncCursor{}, false},
+		{"xid overflow", encode(`{"x":18446744073709551616,"s":1}`), SyncCursor{}, false},
+		{"fractional seq", encode(`{"x":1,"s":1.5}`), SyncCursor{}, false},
+		{"string seq", encode(`{"x":1,"s":"1"}`), SyncCursor{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeSyncCursor(tt.in)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("DecodeSyncCursor(%q) error = %v, want ErrInvalidCursor", tt.in, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeSyncCursor(%q) returned %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeSyncCursor(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncCursorCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b SyncCursor
+		want int
+	}{
+		{"equal", SyncCursor{XID: 5, Seq: 9}, SyncCursor{XID: 5, Seq: 9}, 0},
+		{"earlier seq", SyncCursor{XID: 5, Seq: 8}, SyncCursor{XID: 5, Seq: 9}, -1},
+		{"later seq", SyncCursor{XID: 5, Seq: 10}, SyncCursor{XID: 5, Seq: 9}, 1},
+		{"earlier xid wins over seq", SyncCursor{XID: 4, Seq: 100}, SyncCursor{XID: 5, Seq: 1}, -1},
+		{"later xid wins over seq", SyncCursor{XID: 6, Seq: 1}, SyncCursor{XID: 5, Seq: 100}, 1},
+		{"zero", SyncCursor{}, SyncCursor{XID: 1}, -1},
+		{"largest xid", SyncCursor{XID: math.MaxUint64}, SyncCursor{XID: math.MaxUint64 - 1, Seq: math.MaxInt64}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Compare(tt.b); got != tt.want {
+				t.Errorf("%+v.Compare(%+v) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := tt.b.Compare(tt.a); got != -tt.want {
+				t.Errorf("%+v.Compare(%+v) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"strings"
+)
+
+const MaxTagLength = 32
+
+type TagMatch string
+
+const (
+	// TagMatchAll matches collections that carry every requested tag.
+	TagMatchAll TagMatch = "all"
+	// TagMatchAny matches collections that carry at least one of them.
+	TagMatchAny TagMatch = "any"
+)
+
+// Tag is a tag name with the number of times it is used, as returned by
+// autocomplete.
+type Tag struct {
+	Name  string `json:"name" db:"name"`
+	Count int    `json:"count" db:"count"`
+}
+
+// NormalizeTag lowercases the tag and collapses its whitespace so "Film
+// Noir" and " film  noir" are the same tag.
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" || len(name) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	return name, nil
+}
+
+// NormalizeTags normalizes every tag and drops duplicates, keeping the
+// first occurrence.
+func NormalizeTags(names []string) ([]string, error) {
+	tags := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+type CollectionTagService interface {
+	// AddCollectionTags tags the collection and returns all of its tags.
+	AddCollectionTags(ctx context.Context, collectionID int, tags []string, version *int) ([]string, error)
+
+	// RemoveCollectionTag returns ErrNotFound if the collection does not
+	// carry the tag.
+	RemoveCollectionTag(ctx context.Context, collectionID int, tag string, version *int) error
+
+	// AddImageTags tags a saved image and returns all of its tags, or
+	// ErrNotFound if the image is not in the collection.
+	AddImageTags(ctx context.Context, collectionID int, path string, tags []string, version *int) ([]string, error)
+
+	RemoveImageTag(ctx context.Context, collectionID int, path string, tag string, version *int) error
+
+	// TagSuggestions returns the tags starting with prefix used on
+	// collections the user authored or is a member of, most used first.
+	TagSuggestions(ctx context.Context, userID int, prefix string, limit int) ([]*Tag, error)
+}
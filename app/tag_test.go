@@ -0,0 +1,38 @@
+package app
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+		err  error
+	}{
+		{"none", []string{}, []string{}, nil},
+		{"lowercase", []string{"Film Noir"}, []string{"film noir"}, nil},
+		{"whitespace", []string{"  film \t noir\n"}, []string{"film noir"}, nil},
+		{"duplicates", []string{"Film Noir", "jazz", " film  noir", "JAZZ"}, []string{"film noir", "jazz"}, nil},
+		{"longest", []string{strings.Repeat("a", MaxTagLength)}, []string{strings.Repeat("a", MaxTagLength)}, nil},
+		{"too long", []string{strings.Repeat("a", MaxTagLength+1)}, nil, ErrInvalidTag},
+		{"empty", []string{"jazz", ""}, nil, ErrInvalidTag},
+		{"blank", []string{"jazz", " \t "}, nil, ErrInvalidTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeTags(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NormalizeTags(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("NormalizeTags(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int        `json:"-" db:"id"`
+	UserID    int        `json:"-" db:"user_id"`
+	FamilyID  string     `json:"-" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"-" db:"expires_at"`
+	RevokedAt *time.Time `json:"-" db:"revoked_at"`
+	CreatedAt time.Time  `json:"-" db:"created_at"`
+}
+
+type PasswordReset struct {
+	ID        int        `db:"id"`
+	UserID    int        `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// NewPasswordReset creates a reset for the user that expires after ttl and
+// returns it together with the plain token to mail out.
+func NewPasswordReset(userID int, ttl time.Duration) (string, *PasswordReset, error) {
+	token, hash, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &PasswordReset{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// NewRefreshToken creates a refresh token for the user that expires after
+// ttl. An empty familyID starts a new family. The returned string is the
+// only copy of the plain token; only its hash is stored.
+func NewRefreshToken(userID int, familyID string, ttl time.Duration) (string, *RefreshToken, error) {
+	if familyID == "" {
+		id, err := randomString(16)
+		if err != nil {
+			return "", nil, err
+		}
+		familyID = id
+	}
+
+	token, hash, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// GenerateToken returns a random url-safe token together with the hash that
+// should be persisted in its place.
+func GenerateToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type TokenService interface {
+	CreateRefreshToken(context.Context, *RefreshToken) error
+
+	// RotateRefreshToken revokes the refresh token with the given hash and
+	// stores next in its place; next inherits the user and family of the
+	// rotated token. Presenting an already rotated token revokes the whole
+	// family and returns ErrTokenReused.
+	RotateRefreshToken(ctx context.Context, hash string, next *RefreshToken) error
+
+	// RevokeRefreshToken revokes the family of the user's refresh token with
+	// the given hash.
+	RevokeRefreshToken(ctx context.Context, userID int, hash string) error
+
+	// RevokeAccessToken denies the access token with the given jti until it
+	// would have expired anyway.
+	RevokeAccessToken(ctx context.Context, userID int, jti string, expiresAt time.Time) error
+
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+
+	// RevokeUserTokens invalidates every access and refresh token issued to
+	// the user so far.
+	RevokeUserTokens(ctx context.Context, userID int) error
+
+	CreatePasswordReset(context.Context, *PasswordReset) error
+
+	// ConsumePasswordReset marks the unexpired, unused reset with the given
+	// hash as used, together with every other pending reset of its user.
+	// It returns ErrNotFound when no such reset exists.
+	ConsumePasswordReset(ctx context.Context, hash string) (*PasswordReset, error)
+}
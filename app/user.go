@@ -2,19 +2,21 @@ package app
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID           int       `json:"id,omitempty" db:"id"`
-	Email        string    `json:"email,omitempty" db:"email"`
-	Token        string    `json:"token,omitempty"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	CreatedAt    time.Time `json:"-" db:"created_at"`
-	UpdatedAt    time.Time `json:"-" db:"updated_at"`
+	ID           int        `json:"id,omitempty" db:"id"`
+	Email        string     `json:"email,omitempty" db:"email"`
+	Token        string     `json:"token,omitempty"`
+	RefreshToken string     `json:"refreshToken,omitempty"`
+	PasswordHash string     `json:"-" db:"password_hash"`
+	TokenVersion int        `json:"-" db:"token_version"`
+	VerifiedAt   *time.Time `json:"verifiedAt,omitempty" db:"verified_at"`
+	CreatedAt    time.Time  `json:"-" db:"created_at"`
+	UpdatedAt    time.Time  `json:"-" db:"updated_at"`
 }
 
 func (u *User) User() *User {
@@ -53,7 +55,6 @@ func (u *User) SetPassword(password string) error {
 }
 
 func (u User) VerifyPassword(password string) bool {
-	log.Printf("Running verify password")
 	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
 
 	return err == nil
@@ -63,6 +64,10 @@ func (u *User) IsAnonymous() bool {
 	return u == &AnonymousUser
 }
 
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
 type UserService interface {
 	Authenticate(ctx context.Context, email string, password string) (*User, error)
 
@@ -77,4 +82,8 @@ type UserService interface {
 	UpdateUser(context.Context, *User, UserPatch) error
 
 	DeleteUser(context.Context, uint) error
+
+	// VerifyUserEmail marks the user's email as verified, as long as it is
+	// still the given address.
+	VerifyUserEmail(ctx context.Context, id uint, email string) error
 }
@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer that delivers through the SMTP server at
+// host:port. PLAIN authentication is used when a username is given.
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mail app.Mail) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := formatMessage(m.from, mail)
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{sanitizeHeader(mail.To)}, []byte(msg))
+}
+
+func formatMessage(from string, mail app.Mail) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", sanitizeHeader(from))
+	fmt.Fprintf(&b, "To: %s\r\n", sanitizeHeader(mail.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeHeader(mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	return b.String()
+}
+
+// sanitizeHeader drops line breaks so user supplied values cannot inject
+// extra headers.
+func sanitizeHeader(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
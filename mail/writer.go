@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+// WriterMailer writes every mail to an io.Writer instead of delivering it,
+// which is enough for local development and tests.
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+func NewStdoutMailer(from string) *WriterMailer {
+	return NewWriterMailer(os.Stdout, from)
+}
+
+// NewFileMailer appends every mail to the file at path.
+func NewFileMailer(path string, from string) (*WriterMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWriterMailer(f, from), nil
+}
+
+func (m *WriterMailer) Send(ctx context.Context, mail app.Mail) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := io.WriteString(m.w, formatMessage(m.from, mail)+"\r\n.\r\n")
+	return err
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/Dpalme/posterify-backend/mail"
+	"github.com/Dpalme/posterify-backend/memory"
 	pg "github.com/Dpalme/posterify-backend/postgres"
 	"github.com/Dpalme/posterify-backend/server"
 
@@ -16,11 +22,32 @@ import (
 )
 
 type config struct {
-	port           string
-	dbURI          string
-	migrationSteps uint
+	port            string
+	dbURI           string
+	migrationSteps  uint
+	jwtKeys         string
+	jwtActiveKey    string
+	jwtSecret       string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	appURL          string
+	mailer          string
+	mailFrom        string
+	mailFile        string
+	smtpHost        string
+	smtpPort        string
+	smtpUsername    string
+	smtpPassword    string
+	requireVerified bool
+	rateLimiter     string
+	trashRetention  time.Duration
+	imageDir        string
 }
 
+// trashPurgeInterval is how often collections past the trash retention
+// period are deleted for good.
+const trashPurgeInterval = time.Hour
+
 func main() {
 	cfg := envConfig()
 
@@ -47,7 +74,34 @@ func main() {
 		}
 	}
 
-	srv := server.NewServer(db)
+	keys, err := loadKeySet(cfg)
+	if err != nil {
+		log.Fatalf("cannot load signing keys: %v", err)
+	}
+
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		log.Fatalf("cannot set up mailer: %v", err)
+	}
+
+	var rateLimiter app.RateLimiter = memory.NewRateLimiter()
+	if cfg.rateLimiter == "postgres" {
+		rateLimiter = pg.NewRateLimiter(db)
+	}
+
+	go purgeTrash(pg.NewCollectionService(db), cfg.trashRetention)
+
+	srv := server.NewServer(db, server.Config{
+		Keys:            keys,
+		AccessTokenTTL:  cfg.accessTokenTTL,
+		RefreshTokenTTL: cfg.refreshTokenTTL,
+		Mailer:          mailer,
+		AppURL:          cfg.appURL,
+
+		RequireVerifiedEmail: cfg.requireVerified,
+		RateLimiter:          rateLimiter,
+		ImageDir:             cfg.imageDir,
+	})
 	log.Fatal(srv.Run(cfg.port))
 }
 
@@ -75,5 +129,118 @@ func envConfig() config {
 		panic("MIGRATION_VERSION not a number")
 	}
 
-	return config{port, dbURI, uint(migrationSteps)}
+	cfg := config{
+		port:            port,
+		dbURI:           dbURI,
+		migrationSteps:  uint(migrationSteps),
+		accessTokenTTL:  durationFromEnv("ACCESS_TOKEN_TTL", server.DefaultAccessTokenTTL),
+		refreshTokenTTL: durationFromEnv("REFRESH_TOKEN_TTL", server.DefaultRefreshTokenTTL),
+	}
+
+	cfg.jwtKeys, _ = os.LookupEnv("JWT_KEYS")
+	cfg.jwtActiveKey, _ = os.LookupEnv("JWT_ACTIVE_KEY")
+	cfg.jwtSecret, _ = os.LookupEnv("JWT_SECRET")
+
+	if cfg.jwtKeys == "" && cfg.jwtSecret == "" {
+		panic("JWT_KEYS or JWT_SECRET not provided")
+	}
+
+	if cfg.jwtKeys != "" && cfg.jwtActiveKey == "" {
+		panic("JWT_ACTIVE_KEY not provided")
+	}
+
+	cfg.appURL = stringFromEnv("APP_URL", "https://posterify.dpalmer.in")
+	cfg.mailer = stringFromEnv("MAILER", "stdout")
+	cfg.mailFrom = stringFromEnv("MAIL_FROM", "Posterify <no-reply@posterify.dpalmer.in>")
+	cfg.mailFile = stringFromEnv("MAIL_FILE", "mail.log")
+	cfg.smtpHost, _ = os.LookupEnv("SMTP_HOST")
+	cfg.smtpPort = stringFromEnv("SMTP_PORT", "587")
+	cfg.smtpUsername, _ = os.LookupEnv("SMTP_USERNAME")
+	cfg.smtpPassword, _ = os.LookupEnv("SMTP_PASSWORD")
+
+	cfg.requireVerified = stringFromEnv("REQUIRE_VERIFIED_EMAIL", "false") == "true"
+
+	cfg.rateLimiter = stringFromEnv("RATE_LIMIT_BACKEND", "memory")
+
+	if cfg.rateLimiter != "memory" && cfg.rateLimiter != "postgres" {
+		panic("RATE_LIMIT_BACKEND must be memory or postgres")
+	}
+
+	cfg.trashRetention = durationFromEnv("TRASH_RETENTION", 30*24*time.Hour)
+
+	cfg.imageDir, _ = os.LookupEnv("IMAGE_DIR")
+
+	if cfg.mailer == "smtp" && cfg.smtpHost == "" {
+		panic("SMTP_HOST not provided")
+	}
+
+	return cfg
+}
+
+// purgeTrash periodically deletes the collections that have been in the
+// trash for longer than retention.
+func purgeTrash(collections app.CollectionService, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		n, err := collections.PurgeDeletedCollections(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("cannot purge trash: %v", err)
+		} else if n > 0 {
+			log.Printf("purged %d collections from the trash", n)
+		}
+	}
+}
+
+func newMailer(cfg config) (app.Mailer, error) {
+	switch cfg.mailer {
+	case "smtp":
+		return mail.NewSMTPMailer(cfg.smtpHost, cfg.smtpPort, cfg.smtpUsername, cfg.smtpPassword, cfg.mailFrom), nil
+	case "file":
+		return mail.NewFileMailer(cfg.mailFile, cfg.mailFrom)
+	case "stdout":
+		return mail.NewStdoutMailer(cfg.mailFrom), nil
+	default:
+		return nil, errors.New("MAILER must be one of smtp, file or stdout")
+	}
+}
+
+func stringFromEnv(name string, fallback string) string {
+	v, ok := os.LookupEnv(name)
+
+	if !ok {
+		return fallback
+	}
+
+	return v
+}
+
+// loadKeySet reads the signing keys listed in JWT_KEYS, falling back to a
+// single HS256 key built from JWT_SECRET.
+func loadKeySet(cfg config) (*server.KeySet, error) {
+	if cfg.jwtKeys != "" {
+		return server.LoadKeySet(cfg.jwtActiveKey, cfg.jwtKeys)
+	}
+
+	if len(cfg.jwtSecret) < 32 {
+		return nil, errors.New("JWT_SECRET must be at least 32 bytes")
+	}
+
+	return server.NewHMACKeySet("default", []byte(cfg.jwtSecret)), nil
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(name)
+
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(name + " not a duration")
+	}
+
+	return d
 }
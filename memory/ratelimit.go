@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+// sweepInterval is how often buckets that refilled completely are dropped.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	*app.TokenBucket
+	limit app.RateLimit
+}
+
+// RateLimiter keeps token buckets in process memory. Limits are per
+// instance, so it only suits single instance deployments.
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (rl *RateLimiter) Allow(ctx context.Context, key string, limit app.RateLimit) (app.RateLimitResult, error) {
+	now := time.Now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) > sweepInterval {
+		rl.sweep(now)
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{app.NewTokenBucket(limit, now), limit}
+		rl.buckets[key] = b
+	}
+
+	return b.Take(limit, now), nil
+}
+
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, b := range rl.buckets {
+		if b.IsFull(b.limit, now) {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
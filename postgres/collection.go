@@ -2,11 +2,16 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"time"
 
 	"github.com/Dpalme/posterify-backend/app"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type CollectionService struct {
@@ -30,6 +35,10 @@ func (cs *CollectionService) CreateCollection(ctx context.Context, collection *a
 		return err
 	}
 
+	if err := recordRevision(ctx, tx, collection.ID, app.RevisionCreate); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -56,7 +65,7 @@ func (cs *CollectionService) CollectionByID(ctx context.Context, id int) (*app.C
 	return collection, nil
 }
 
-func (cs *CollectionService) Collections(ctx context.Context, cf app.CollectionFilter) ([]*app.Collection, error) {
+func (cs *CollectionService) Collections(ctx context.Context, cf app.CollectionFilter) (*app.CollectionPage, error) {
 	tx, err := cs.db.BeginTxx(ctx, nil)
 
 	if err != nil {
@@ -65,16 +74,16 @@ func (cs *CollectionService) Collections(ctx context.Context, cf app.CollectionF
 
 	defer tx.Rollback()
 
-	collections, err := findCollections(ctx, tx, cf)
+	page, err := findCollectionPage(ctx, tx, cf)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return collections, tx.Commit()
+	return page, tx.Commit()
 }
 
-func (cs *CollectionService) UpdateCollection(ctx context.Context, collection *app.Collection, patch app.CollectionPatch) error {
+func (cs *CollectionService) UpdateCollection(ctx context.Context, collection *app.Collection, patch app.CollectionPatch, version *int) error {
 	tx, err := cs.db.BeginTxx(ctx, nil)
 
 	if err != nil {
@@ -84,9 +93,16 @@ func (cs *CollectionService) UpdateCollection(ctx context.Context, collection *a
 
 	defer tx.Rollback()
 
+	if err := lockCollectionVersion(ctx, tx, collection.ID, version); err != nil {
+		return err
+	}
+
 	if err := updateCollection(ctx, tx, collection, patch); err != nil {
-		log.Println(err)
-		return app.ErrInternal
+		return err
+	}
+
+	if err := recordRevision(ctx, tx, collection.ID, app.RevisionUpdate); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -97,7 +113,7 @@ func (cs *CollectionService) UpdateCollection(ctx context.Context, collection *a
 	return nil
 }
 
-func (cs *CollectionService) DeleteCollection(ctx context.Context, id int) error {
+func (cs *CollectionService) DeleteCollection(ctx context.Context, id int, version *int) error {
 	tx, err := cs.db.BeginTxx(ctx, nil)
 
 	if err != nil {
@@ -107,17 +123,45 @@ func (cs *CollectionService) DeleteCollection(ctx context.Context, id int) error
 
 	defer tx.Rollback()
 
-	_, err = cs.CollectionByID(ctx, id)
-	if err != nil {
+	if err := lockCollectionVersion(ctx, tx, id, version); err != nil {
+		return err
+	}
+
+	if err := trashCollection(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return app.ErrInternal
 	}
 
-	if err := deleteCollection(ctx, tx, &id); err != nil {
+	return nil
+}
+
+func (cs *CollectionService) SaveImageToCollection(ctx context.Context, c_id int, image *app.Image, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
 		log.Println(err)
 		return app.ErrInternal
 	}
 
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, c_id, version); err != nil {
+		return err
+	}
+
+	collection := &app.Collection{ID: c_id}
+	if err := saveToCollection(ctx, tx, collection, image); err != nil {
+		return err
+	}
+
+	if err := recordRevision(ctx, tx, c_id, app.RevisionSaveImage); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return app.ErrInternal
@@ -126,7 +170,7 @@ func (cs *CollectionService) DeleteCollection(ctx context.Context, id int) error
 	return nil
 }
 
-func (cs *CollectionService) SaveImageToCollection(ctx context.Context, c_id int, image string) error {
+func (cs *CollectionService) DeleteImageFromCollection(ctx context.Context, c_id int, imagePath string, version *int) error {
 	tx, err := cs.db.BeginTxx(ctx, nil)
 
 	if err != nil {
@@ -136,46 +180,157 @@ func (cs *CollectionService) SaveImageToCollection(ctx context.Context, c_id int
 
 	defer tx.Rollback()
 
-	collection, err := cs.CollectionByID(ctx, c_id)
-	if err != nil {
+	collection := &app.Collection{ID: c_id}
+	if err := lockCollectionVersion(ctx, tx, c_id, version); err != nil {
+		return err
+	}
+
+	if err := removeFromCollection(ctx, tx, collection, &imagePath); err != nil {
+		return err
+	}
+
+	if err := recordRevision(ctx, tx, c_id, app.RevisionDeleteImage); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return app.ErrInternal
 	}
 
-	if err := saveToCollection(ctx, tx, collection, image); err != nil {
+	return nil
+}
+
+func (cs *CollectionService) BatchUpdateImages(ctx context.Context, c_id int, batch app.ImageBatch, version *int) ([]*app.BatchImageResult, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
 		log.Println(err)
-		return app.ErrInternal
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, c_id, version); err != nil {
+		return nil, err
+	}
+
+	removed, err := removeManyFromCollection(ctx, tx, c_id, batch.Remove)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := saveManyToCollection(ctx, tx, c_id, batch.Add)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*app.BatchImageResult, 0, len(batch.Add)+len(batch.Remove))
+	for _, image := range batch.Add {
+		status := app.BatchImageAlreadyPresent
+		if added[image.Path] {
+			status = app.BatchImageAdded
+			// Only the first of repeated paths is added.
+			delete(added, image.Path)
+		}
+		results = append(results, &app.BatchImageResult{Path: image.Path, Status: status})
+	}
+	for _, path := range batch.Remove {
+		status := app.BatchImageNotFound
+		if removed[path] {
+			status = app.BatchImageRemoved
+			delete(removed, path)
+		}
+		results = append(results, &app.BatchImageResult{Path: path, Status: status})
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: c_id}); err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, c_id, app.RevisionBatchImages); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
 		log.Println(err)
-		return app.ErrInternal
+		return nil, app.ErrInternal
 	}
 
-	return nil
+	return results, nil
 }
 
-func (cs *CollectionService) DeleteImageFromCollection(ctx context.Context, c_id int, imagePath string) error {
+func (cs *CollectionService) UpdateImage(ctx context.Context, c_id int, imagePath string, patch app.ImagePatch, version *int) (*app.Image, error) {
 	tx, err := cs.db.BeginTxx(ctx, nil)
 
 	if err != nil {
 		log.Println(err)
-		return app.ErrInternal
+		return nil, app.ErrInternal
 	}
 
 	defer tx.Rollback()
 
-	collection, err := cs.CollectionByID(ctx, c_id)
+	if err := lockCollectionVersion(ctx, tx, c_id, version); err != nil {
+		return nil, err
+	}
+
+	image, err := findImageForUpdate(ctx, tx, c_id, imagePath)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
 		log.Println(err)
-		return app.ErrInternal
+		return nil, app.ErrInternal
 	}
 
-	if err := removeFromCollection(ctx, tx, collection, &imagePath); err != nil {
+	image.Apply(patch)
+
+	if err := updateImage(ctx, tx, image); err != nil {
+		return nil, err
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: c_id}); err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, c_id, app.RevisionUpdateImage); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return image, nil
+}
+
+func (cs *CollectionService) ReorderImages(ctx context.Context, c_id int, paths []string, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
 		log.Println(err)
 		return app.ErrInternal
 	}
 
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, c_id, version); err != nil {
+		return err
+	}
+
+	if err := reorderImages(ctx, tx, c_id, paths); err != nil {
+		return err
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: c_id}); err != nil {
+		return err
+	}
+
+	if err := recordRevision(ctx, tx, c_id, app.RevisionReorder); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return app.ErrInternal
@@ -184,31 +339,119 @@ func (cs *CollectionService) DeleteImageFromCollection(ctx context.Context, c_id
 	return nil
 }
 
+// lockCollection serializes writers of the collection's images for the rest
+// of the transaction.
+func lockCollection(ctx context.Context, tx *sqlx.Tx, id int) error {
+	var locked int
+	return tx.QueryRowxContext(ctx, `SELECT id FROM collections WHERE id = $1 FOR UPDATE`, id).Scan(&locked)
+}
+
+// lockCollectionVersion locks the collection and, when version is set,
+// checks the collection is still at it. The lock keeps the version from
+// changing until the transaction ends.
+func lockCollectionVersion(ctx context.Context, tx *sqlx.Tx, id int, version *int) error {
+	var current int
+	err := tx.QueryRowxContext(ctx, `SELECT version FROM collections WHERE id = $1 FOR UPDATE`, id).Scan(&current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return app.ErrNotFound
+	case err != nil:
+		log.Printf("error locking collection: %v", err)
+		return app.ErrInternal
+	case version != nil && current != *version:
+		return app.ErrVersionMismatch
+	}
+	return nil
+}
+
+func reorderImages(ctx context.Context, tx *sqlx.Tx, collectionID int, paths []string) error {
+	current := []string{}
+	query := `SELECT img_path FROM collections_images WHERE collection_id = $1`
+
+	if err := tx.SelectContext(ctx, &current, query, collectionID); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	// The client must have seen exactly the images that are stored now,
+	// otherwise it is reordering a list that changed underneath it.
+	if len(current) != len(paths) {
+		return app.ErrStaleImageOrder
+	}
+
+	seen := make(map[string]bool, len(paths))
+	for _, p := range current {
+		seen[p] = false
+	}
+	for _, p := range paths {
+		if done, ok := seen[p]; !ok || done {
+			return app.ErrStaleImageOrder
+		}
+		seen[p] = true
+	}
+
+	query = `
+	UPDATE collections_images AS ci
+	SET position = o.position - 1
+	FROM unnest($2::text[]) WITH ORDINALITY AS o(img_path, position)
+	WHERE ci.collection_id = $1 AND ci.img_path = o.img_path`
+
+	if _, err := tx.ExecContext(ctx, query, collectionID, pq.Array(paths)); err != nil {
+		log.Printf("error updating record: %v", err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+// markCollectionUpdate bumps the collection's version and updated_at.
 func markCollectionUpdate(ctx context.Context, tx *sqlx.Tx, collection *app.Collection) error {
 	query := `
 	UPDATE collections
-	SET updated_at = NOW()
+	SET updated_at = NOW(), version = version + 1
 	WHERE id = $1
-	RETURNING updated_at`
-	err := tx.QueryRowxContext(ctx, query, collection.ID).Scan(&collection.UpdatedAt)
+	RETURNING updated_at, version`
+
+	err := tx.QueryRowxContext(ctx, query, collection.ID).Scan(&collection.UpdatedAt, &collection.Version)
 	if err != nil {
-		return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return app.ErrNotFound
+		}
+		log.Println(err)
+		return app.ErrInternal
 	}
 	return nil
 }
 
+// createCollection inserts the collection. It is created now unless it
+// already has a creation time.
 func createCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collection) error {
 	query := `
-	INSERT INTO collections (name, description, poster, author_id)
-	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at, author_id
+	INSERT INTO collections (name, description, poster, author_id, visibility, forked_from, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, NOW())) RETURNING id, created_at, updated_at, author_id
 	`
-	args := []interface{}{collection.Name, collection.Description, collection.Poster, collection.AuthorID}
+	if collection.Visibility == "" {
+		collection.Visibility = app.VisibilityPrivate
+	}
+	var createdAt *time.Time
+	if !collection.CreatedAt.IsZero() {
+		createdAt = &collection.CreatedAt
+	}
+	args := []interface{}{
+		collection.Name,
+		collection.Description,
+		collection.Poster,
+		collection.AuthorID,
+		collection.Visibility,
+		collection.ForkedFrom,
+		createdAt,
+	}
 	err := tx.QueryRowxContext(ctx, query, args...).Scan(&collection.ID, &collection.CreatedAt, &collection.UpdatedAt, &collection.AuthorID)
 
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "collection_name_user_key"`:
-			return app.ErrDuplicateEmail
+		case isDuplicateCollectionName(err):
+			return app.ErrDuplicateName
 		default:
 			return err
 		}
@@ -217,8 +460,22 @@ func createCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collecti
 	return nil
 }
 
+const collectionColumns = `id, author_id, name, description, poster, visibility, image_count,
+	forked_from, fork_count, version, created_at, updated_at, deleted_at`
+
+// isUniqueViolation reports whether err violates the named unique
+// constraint or index.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func isDuplicateCollectionName(err error) bool {
+	return isUniqueViolation(err, "unique_name_author")
+}
+
 func findCollectionByID(ctx context.Context, tx *sqlx.Tx, id int) (*app.Collection, error) {
-	return findOneCollection(ctx, tx, app.CollectionFilter{ID: &id})
+	return findOneCollection(ctx, tx, app.CollectionFilter{ID: &id, IncludeImages: true})
 }
 
 func findOneCollection(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFilter) (*app.Collection, error) {
@@ -234,7 +491,85 @@ func findOneCollection(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFi
 	return cs[0], nil
 }
 
-func findCollections(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFilter) ([]*app.Collection, error) {
+// findCollectionPage returns a page of the collections matching filter,
+// the cursors around it and the total number of matches.
+func findCollectionPage(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFilter) (*app.CollectionPage, error) {
+	page := &app.CollectionPage{}
+
+	where, args := collectionWhere(filter)
+	query := "SELECT COUNT(*) from collections" + formatWhereClause(where)
+	if err := tx.GetContext(ctx, &page.Total, query, args...); err != nil {
+		log.Printf("error counting collections: %v", err)
+		return nil, err
+	}
+
+	// Fetch one extra row to learn whether there is another page.
+	lookahead := filter
+	if filter.Limit > 0 {
+		lookahead.Limit = filter.Limit + 1
+	}
+
+	collections, err := findCollections(ctx, tx, lookahead)
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := filter.Limit > 0 && len(collections) > filter.Limit
+	if hasMore {
+		if filter.Before != nil {
+			collections = collections[1:]
+		} else {
+			collections = collections[:filter.Limit]
+		}
+	}
+	page.Collections = collections
+
+	if len(collections) == 0 {
+		return page, nil
+	}
+
+	sort, order := filter.Sort, filter.Order
+	if sort == "" {
+		sort = app.SortByCreated
+	}
+	if order == "" {
+		order = app.SortAsc
+	}
+
+	first, last := collections[0], collections[len(collections)-1]
+	if filter.Before != nil {
+		page.Next = app.CollectionCursor(last, sort, order)
+		if hasMore {
+			page.Prev = app.CollectionCursor(first, sort, order)
+		}
+	} else {
+		if hasMore {
+			page.Next = app.CollectionCursor(last, sort, order)
+		}
+		if filter.After != nil || filter.Offset > 0 {
+			page.Prev = app.CollectionCursor(first, sort, order)
+		}
+	}
+
+	return page, nil
+}
+
+// collectionSortColumn returns the column a listing is sorted by and the
+// type its cursor values are cast to.
+func collectionSortColumn(sort app.CollectionSort) (string, string) {
+	switch sort {
+	case app.SortByName:
+		return "name", "text"
+	case app.SortByUpdated:
+		return "updated_at", "timestamptz"
+	case app.SortByImages:
+		return "image_count", "integer"
+	default:
+		return "created_at", "timestamptz"
+	}
+}
+
+func collectionWhere(filter app.CollectionFilter) ([]string, []interface{}) {
 	where, args := []string{}, []interface{}{}
 	argPosition := 0
 
@@ -248,8 +583,84 @@ func findCollections(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFilt
 		where, args = append(where, fmt.Sprintf("name = $%d", argPosition)), append(args, *v)
 	}
 
-	query := "SELECT * from collections" + formatWhereClause(where) +
-		" ORDER BY id ASC" + formatLimitOffset(filter.Limit, filter.Offset)
+	if v := filter.AuthorId; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("author_id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.Visibility; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("visibility = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.MemberID; v != nil {
+		argPosition++
+		where = append(where, fmt.Sprintf(
+			"(author_id = $%[1]d OR id IN (SELECT collection_id FROM collection_members WHERE user_id = $%[1]d))",
+			argPosition,
+		))
+		args = append(args, *v)
+	}
+
+	if len(filter.Tags) > 0 {
+		argPosition++
+		tagCondition := fmt.Sprintf(`id IN (
+			SELECT ct.collection_id
+			FROM collections_tags AS ct
+			JOIN tags AS t ON t.id = ct.tag_id
+			WHERE t.name = ANY($%d)`, argPosition)
+		args = append(args, pq.Array(filter.Tags))
+
+		if filter.TagMatch != app.TagMatchAny {
+			argPosition++
+			tagCondition += fmt.Sprintf(" GROUP BY ct.collection_id HAVING COUNT(*) = $%d", argPosition)
+			args = append(args, len(filter.Tags))
+		}
+
+		where = append(where, tagCondition+")")
+	}
+
+	if filter.Deleted {
+		where = append(where, "deleted_at IS NOT NULL")
+	} else {
+		where = append(where, "deleted_at IS NULL")
+	}
+
+	return where, args
+}
+
+func findCollections(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFilter) ([]*app.Collection, error) {
+	where, args := collectionWhere(filter)
+	argPosition := len(args)
+
+	column, cast := collectionSortColumn(filter.Sort)
+	descending := filter.Order == app.SortDesc
+
+	// Paging backwards walks the listing in reverse from the cursor and
+	// flips the page back afterwards.
+	cursor := filter.After
+	if filter.Before != nil {
+		cursor = filter.Before
+		descending = !descending
+	}
+
+	if cursor != nil {
+		op := ">"
+		if descending {
+			op = "<"
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)", column, op, argPosition+1, cast, argPosition+2))
+		args = append(args, cursor.Value, cursor.ID)
+	}
+
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+
+	query := "SELECT " + collectionColumns + " from collections" + formatWhereClause(where) +
+		fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s", column, direction) +
+		formatLimitOffset(filter.Limit, filter.Offset)
 	collections, err := queryCollections(ctx, tx, query, args...)
 
 	if err != nil {
@@ -257,9 +668,66 @@ func findCollections(ctx context.Context, tx *sqlx.Tx, filter app.CollectionFilt
 		return nil, err
 	}
 
+	if filter.Before != nil {
+		slices.Reverse(collections)
+	}
+
+	if err := attachCollectionTags(ctx, tx, collections); err != nil {
+		log.Printf("error loading collection tags: %v", err)
+		return nil, err
+	}
+
+	if filter.IncludeImages {
+		if err := attachCollectionImages(ctx, tx, collections); err != nil {
+			log.Printf("error loading collection images: %v", err)
+			return nil, err
+		}
+	}
+
 	return collections, nil
 }
 
+const imageColumns = `img_path, collection_id, position, caption, alt_text, source_url,
+	attribution, width, height, dominant_color, created_at`
+
+// attachCollectionImages loads the images of every given collection with a
+// single query and assigns them in display order.
+func attachCollectionImages(ctx context.Context, tx *sqlx.Tx, collections []*app.Collection) error {
+	if len(collections) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(collections))
+	byID := make(map[int]*app.Collection, len(collections))
+	for i, c := range collections {
+		ids[i] = int64(c.ID)
+		byID[c.ID] = c
+		c.Images = []*app.Image{}
+	}
+
+	query := "SELECT " + imageColumns + `
+	FROM collections_images
+	WHERE collection_id = ANY($1)
+	ORDER BY position ASC, created_at ASC`
+
+	images := []*app.Image{}
+	if err := tx.SelectContext(ctx, &images, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	if err := attachImageTags(ctx, tx, ids, images); err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		if c, ok := byID[image.CollectionId]; ok {
+			c.Images = append(c.Images, image)
+		}
+	}
+
+	return nil
+}
+
 func updateCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collection, patch app.CollectionPatch) error {
 	if v := patch.Name; v != nil {
 		collection.Name = *v
@@ -273,20 +741,31 @@ func updateCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collecti
 		collection.Poster = *v
 	}
 
+	if v := patch.Visibility; v != nil {
+		collection.Visibility = *v
+	}
+
 	args := []interface{}{
 		collection.Name,
 		collection.Description,
 		collection.Poster,
+		collection.Visibility,
 		collection.ID,
 	}
 
 	query := `
 	UPDATE collections 
-	SET name = $1, description = $2, poster = $3, updated_at = NOW()
-	WHERE id = $4
-	RETURNING updated_at`
+	SET name = $1, description = $2, poster = $3, visibility = $4, updated_at = NOW(), version = version + 1
+	WHERE id = $5
+	RETURNING updated_at, version`
 
-	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&collection.UpdatedAt); err != nil {
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&collection.UpdatedAt, &collection.Version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return app.ErrNotFound
+		}
+		if isDuplicateCollectionName(err) {
+			return app.ErrDuplicateName
+		}
 		log.Printf("error updating record: %v", err)
 		return app.ErrInternal
 	}
@@ -305,27 +784,197 @@ func queryCollections(ctx context.Context, tx *sqlx.Tx, query string, args ...in
 	return collections, nil
 }
 
-func saveToCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collection, imgPath string) error {
+func saveToCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collection, image *app.Image) error {
 	args := []interface{}{
-		imgPath,
+		image.Path,
 		collection.ID,
+		image.Caption,
+		image.AltText,
+		image.SourceURL,
+		image.Attribution,
+		image.Width,
+		image.Height,
+		image.DominantColor,
+	}
+
+	if err := lockCollection(ctx, tx, collection.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return app.ErrNotFound
+		}
+		log.Printf("error locking collection: %v", err)
+		return app.ErrInternal
 	}
 
+	// New images go after every image already in the collection.
 	query := `
-	INSERT INTO collections_images (img_path, collection_id, created_at)
-	VALUES ($1, $2, NOW())
-	RETURNING created_at`
+	INSERT INTO collections_images (
+		img_path, collection_id, position, caption, alt_text, source_url,
+		attribution, width, height, dominant_color, created_at
+	)
+	SELECT $1::text, $2::int, COALESCE(MAX(position) + 1, 0), $3::text, $4::text, $5::text,
+		$6::text, $7::int, $8::int, $9::text, NOW()
+	FROM collections_images
+	WHERE collection_id = $2
+	RETURNING collection_id, position, created_at`
 
-	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&collection.UpdatedAt); err != nil {
+	err := tx.QueryRowxContext(ctx, query, args...).Scan(&image.CollectionId, &image.Position, &image.SavedAt)
+	if err != nil {
+		// A concurrent request may have saved the image since the handler
+		// checked.
+		if isUniqueViolation(err, "collections_images_pkey") {
+			return app.ErrImageAlreadySaved
+		}
 		log.Printf("error creating record: %v", err)
 		return app.ErrInternal
 	}
 
-	markCollectionUpdate(ctx, tx, collection)
+	return markCollectionUpdate(ctx, tx, collection)
+}
+
+// saveManyToCollection appends the images in order with one statement,
+// skipping those already saved, and returns the paths it added. The caller
+// must hold the collection lock.
+func saveManyToCollection(ctx context.Context, tx *sqlx.Tx, collectionID int, images []*app.Image) (map[string]bool, error) {
+	added := map[string]bool{}
+	if len(images) == 0 {
+		return added, nil
+	}
+
+	n := len(images)
+	paths, captions, altTexts := make([]string, n), make([]string, n), make([]string, n)
+	sourceURLs, attributions, colors := make([]string, n), make([]string, n), make([]string, n)
+	widths, heights := make([]int64, n), make([]int64, n)
+	savedAt := make([]string, n)
+	for i, image := range images {
+		paths[i], captions[i], altTexts[i] = image.Path, image.Caption, image.AltText
+		sourceURLs[i], attributions[i], colors[i] = image.SourceURL, image.Attribution, image.DominantColor
+		widths[i], heights[i] = int64(image.Width), int64(image.Height)
+		// Images are saved now unless they already have a time.
+		if !image.SavedAt.IsZero() {
+			savedAt[i] = image.SavedAt.Format(time.RFC3339Nano)
+		}
+	}
+
+	// DISTINCT ON keeps the first of repeated paths so the insert never
+	// conflicts with itself.
+	query := `
+	INSERT INTO collections_images (
+		img_path, collection_id, position, caption, alt_text, source_url,
+		attribution, width, height, dominant_color, created_at
+	)
+	SELECT i.img_path, $1, base.next + i.n - 1, i.caption, i.alt_text, i.source_url,
+		i.attribution, i.width, i.height, i.dominant_color, COALESCE(NULLIF(i.saved_at, '')::timestamptz, NOW())
+	FROM (
+		SELECT DISTINCT ON (img_path) *
+		FROM unnest($2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::int[], $8::int[], $9::text[], $10::text[])
+			WITH ORDINALITY AS u(img_path, caption, alt_text, source_url, attribution, width, height, dominant_color, saved_at, n)
+		ORDER BY img_path, n
+	) AS i
+	CROSS JOIN (
+		SELECT COALESCE(MAX(position) + 1, 0) AS next
+		FROM collections_images
+		WHERE collection_id = $1
+	) AS base
+	ORDER BY i.n
+	ON CONFLICT (img_path, collection_id) DO NOTHING
+	RETURNING img_path`
+
+	args := []interface{}{
+		collectionID,
+		pq.Array(paths),
+		pq.Array(captions),
+		pq.Array(altTexts),
+		pq.Array(sourceURLs),
+		pq.Array(attributions),
+		pq.Array(widths),
+		pq.Array(heights),
+		pq.Array(colors),
+		pq.Array(savedAt),
+	}
+
+	paths = []string{}
+	if err := tx.SelectContext(ctx, &paths, query, args...); err != nil {
+		log.Printf("error creating records: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	for _, path := range paths {
+		added[path] = true
+	}
+
+	return added, nil
+}
+
+// removeManyFromCollection deletes the images with one statement and
+// returns the paths that were saved.
+func removeManyFromCollection(ctx context.Context, tx *sqlx.Tx, collectionID int, paths []string) (map[string]bool, error) {
+	removed := map[string]bool{}
+	if len(paths) == 0 {
+		return removed, nil
+	}
+
+	query := `
+	DELETE
+	FROM collections_images
+	WHERE collection_id = $1 AND img_path = ANY($2)
+	RETURNING img_path`
+
+	deleted := []string{}
+	if err := tx.SelectContext(ctx, &deleted, query, collectionID, pq.Array(paths)); err != nil {
+		log.Printf("error deleting records: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	for _, path := range deleted {
+		removed[path] = true
+	}
+
+	return removed, nil
+}
+
+func findImageForUpdate(ctx context.Context, tx *sqlx.Tx, collectionID int, imgPath string) (*app.Image, error) {
+	image := &app.Image{}
+	query := "SELECT " + imageColumns + `
+	FROM collections_images
+	WHERE collection_id = $1 AND img_path = $2
+	FOR UPDATE`
+
+	if err := tx.GetContext(ctx, image, query, collectionID, imgPath); err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+func updateImage(ctx context.Context, tx *sqlx.Tx, image *app.Image) error {
+	args := []interface{}{
+		image.Caption,
+		image.AltText,
+		image.SourceURL,
+		image.Attribution,
+		image.Width,
+		image.Height,
+		image.DominantColor,
+		image.CollectionId,
+		image.Path,
+	}
+
+	query := `
+	UPDATE collections_images
+	SET caption = $1, alt_text = $2, source_url = $3, attribution = $4,
+		width = $5, height = $6, dominant_color = $7
+	WHERE collection_id = $8 AND img_path = $9`
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		log.Printf("error updating record: %v", err)
+		return app.ErrInternal
+	}
 
 	return nil
 }
 
+// removeFromCollection deletes the image. A trigger leaves a tombstone for
+// syncing clients.
 func removeFromCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Collection, imgPath *string) error {
 	args := []interface{}{
 		imgPath,
@@ -342,11 +991,11 @@ func removeFromCollection(ctx context.Context, tx *sqlx.Tx, collection *app.Coll
 		return app.ErrInternal
 	}
 
-	markCollectionUpdate(ctx, tx, collection)
-
-	return nil
+	return markCollectionUpdate(ctx, tx, collection)
 }
 
+// deleteCollection deletes the collection and its images. A trigger leaves a
+// tombstone for syncing clients.
 func deleteCollection(ctx context.Context, tx *sqlx.Tx, collection_id *int) error {
 	args := []interface{}{
 		collection_id,
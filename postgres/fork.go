@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxCollectionName is the length of collections.name.
+const maxCollectionName = 64
+
+func (cs *CollectionService) ForkCollection(ctx context.Context, sourceID int, authorID int) (*app.Collection, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	source, err := findCollectionByID(ctx, tx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := forkName(ctx, tx, authorID, source.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	fork := &app.Collection{
+		Name:        name,
+		Description: source.Description,
+		Poster:      source.Poster,
+		AuthorID:    authorID,
+		Visibility:  app.VisibilityPrivate,
+		ForkedFrom:  &source.ID,
+	}
+
+	if err := createCollection(ctx, tx, fork); err != nil {
+		if errors.Is(err, app.ErrDuplicateName) {
+			return nil, err
+		}
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	paths := make([]string, len(source.Images))
+	for i, image := range source.Images {
+		paths[i] = image.Path
+	}
+
+	if _, err := copyImages(ctx, tx, source.ID, []int{fork.ID}, paths, false); err != nil {
+		return nil, err
+	}
+
+	if len(source.Tags) > 0 {
+		query := `
+		INSERT INTO collections_tags (collection_id, tag_id)
+		SELECT $1, tag_id FROM collections_tags WHERE collection_id = $2`
+
+		if _, err := tx.ExecContext(ctx, query, fork.ID, source.ID); err != nil {
+			log.Printf("error creating record: %v", err)
+			return nil, app.ErrInternal
+		}
+	}
+
+	if err := recordRevision(ctx, tx, fork.ID, app.RevisionFork); err != nil {
+		return nil, err
+	}
+
+	fork, err = findCollectionByID(ctx, tx, fork.ID)
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return fork, nil
+}
+
+// forkName returns a name for the fork that the author does not use yet.
+func forkName(ctx context.Context, tx *sqlx.Tx, authorID int, name string) (string, error) {
+	names := []string{}
+	query := `SELECT name FROM collections WHERE author_id = $1 AND deleted_at IS NULL`
+
+	if err := tx.SelectContext(ctx, &names, query, authorID); err != nil {
+		log.Println(err)
+		return "", app.ErrInternal
+	}
+
+	taken := make(map[string]bool, len(names))
+	for _, n := range names {
+		taken[n] = true
+	}
+
+	return freeForkName(name, taken), nil
+}
+
+// freeForkName returns name, or name with the lowest " (n)" suffix that is
+// not taken, shortened by runes to fit the name column.
+func freeForkName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+
+	for n := 2; ; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		base := name
+		for utf8.RuneCountInString(base)+len(suffix) > maxCollectionName {
+			_, size := utf8.DecodeLastRuneInString(base)
+			base = base[:len(base)-size]
+		}
+
+		if candidate := base + suffix; !taken[candidate] {
+			return candidate
+		}
+	}
+}
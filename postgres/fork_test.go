@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFreeForkName(t *testing.T) {
+	long := strings.Repeat("a", maxCollectionName)
+	wide := strings.Repeat("é", maxCollectionName)
+
+	// upTo lists base and base suffixed " (2)" to " (n)".
+	upTo := func(base string, n int) []string {
+		names := []string{base}
+		for i := 2; i <= n; i++ {
+			names = append(names, fmt.Sprintf("%s (%d)", base, i))
+		}
+		return names
+	}
+
+	tests := []struct {
+		name  string
+		in    string
+		taken []string
+		want  string
+	}{
+		{"free", "Posters", nil, "Posters"},
+		{"free despite suffixed", "Posters", []string{"Posters (2)"}, "Posters"},
+		{"taken", "Posters", []string{"Posters"}, "Posters (2)"},
+		{"lowest free", "Posters", []string{"Posters", "Posters (2)", "Posters (4)"}, "Posters (3)"},
+		{"two digits", "Posters", upTo("Posters", 9), "Posters (10)"},
+		{"truncated", long, []string{long}, long[:maxCollectionName-4] + " (2)"},
+		{"truncated more for two digits", long, append([]string{long}, upTo(long[:maxCollectionName-4], 9)[1:]...), long[:maxCollectionName-5] + " (10)"},
+		{"truncated by rune", wide, []string{wide}, strings.Repeat("é", maxCollectionName-4) + " (2)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := map[string]bool{}
+			for _, name := range tt.taken {
+				taken[name] = true
+			}
+
+			got := freeForkName(tt.in, taken)
+			if got != tt.want {
+				t.Errorf("freeForkName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) || utf8.RuneCountInString(got) > maxCollectionName {
+				t.Errorf("freeForkName(%q) = %q, does not fit the name column", tt.in, got)
+			}
+		})
+	}
+}
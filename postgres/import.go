@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ImportCollections runs the whole import in one transaction. Dry runs do
+// the same work and roll it back, so their report matches a real import.
+func (cs *CollectionService) ImportCollections(ctx context.Context, imp app.CollectionImport) ([]*app.ImportResult, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	results := make([]*app.ImportResult, 0, len(imp.Collections))
+	for _, c := range imp.Collections {
+		result, err := importCollection(ctx, tx, imp.AuthorID, c, imp.Strategy)
+		if err != nil {
+			return nil, err
+		}
+		if imp.DryRun {
+			result.CollectionID = nil
+		}
+		results = append(results, result)
+	}
+
+	if imp.DryRun {
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return results, nil
+}
+
+func importCollection(ctx context.Context, tx *sqlx.Tx, authorID int, c *app.Collection, strategy app.ImportStrategy) (*app.ImportResult, error) {
+	result := &app.ImportResult{Name: c.Name, Status: app.ImportCreated, ImportedAs: c.Name}
+
+	var existingID int
+	query := `
+	SELECT id
+	FROM collections
+	WHERE author_id = $1 AND name = $2 AND deleted_at IS NULL
+	FOR UPDATE`
+
+	err := tx.GetContext(ctx, &existingID, query, authorID, c.Name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		log.Println(err)
+		return nil, app.ErrInternal
+	case strategy == app.ImportSkip:
+		result.Status = app.ImportSkipped
+		result.ImportedAs = ""
+		result.ImagesSkipped = len(c.Images)
+		return result, nil
+	case strategy == app.ImportMerge:
+		result.Status = app.ImportMerged
+		result.CollectionID = &existingID
+		return result, mergeImport(ctx, tx, existingID, c, result)
+	default:
+		name, err := forkName(ctx, tx, authorID, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		result.Status = app.ImportRenamed
+		result.ImportedAs = name
+	}
+
+	collection := &app.Collection{
+		Name:        result.ImportedAs,
+		Description: c.Description,
+		Poster:      c.Poster,
+		AuthorID:    authorID,
+		Visibility:  c.Visibility,
+		CreatedAt:   c.CreatedAt,
+	}
+
+	if err := createCollection(ctx, tx, collection); err != nil {
+		if errors.Is(err, app.ErrDuplicateName) {
+			return nil, err
+		}
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+	result.CollectionID = &collection.ID
+
+	if _, err := importTags(ctx, tx, collection.ID, c.Tags); err != nil {
+		return nil, err
+	}
+
+	if err := importImages(ctx, tx, collection.ID, c.Images, result); err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, collection.ID, app.RevisionImport); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// mergeImport adds the images and tags of c that the collection is missing.
+// The caller must hold the collection lock.
+func mergeImport(ctx context.Context, tx *sqlx.Tx, collectionID int, c *app.Collection, result *app.ImportResult) error {
+	tagged, err := importTags(ctx, tx, collectionID, c.Tags)
+	if err != nil {
+		return err
+	}
+
+	if err := importImages(ctx, tx, collectionID, c.Images, result); err != nil {
+		return err
+	}
+
+	if tagged == 0 && result.ImagesAdded == 0 {
+		return nil
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: collectionID}); err != nil {
+		return err
+	}
+
+	return recordRevision(ctx, tx, collectionID, app.RevisionImport)
+}
+
+// importTags tags the collection and returns how many of the tags are new
+// to it.
+func importTags(ctx context.Context, tx *sqlx.Tx, collectionID int, tags []string) (int64, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+
+	if err := createTags(ctx, tx, tags); err != nil {
+		return 0, err
+	}
+
+	query := `
+	INSERT INTO collections_tags (collection_id, tag_id)
+	SELECT $1, id FROM tags WHERE name = ANY($2)
+	ON CONFLICT DO NOTHING`
+
+	res, err := tx.ExecContext(ctx, query, collectionID, pq.Array(tags))
+	if err != nil {
+		log.Printf("error creating record: %v", err)
+		return 0, app.ErrInternal
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return 0, app.ErrInternal
+	}
+
+	return n, nil
+}
+
+// importImages appends the images the collection does not have yet, with
+// their tags, and counts them in result.
+func importImages(ctx context.Context, tx *sqlx.Tx, collectionID int, images []*app.Image, result *app.ImportResult) error {
+	added, err := saveManyToCollection(ctx, tx, collectionID, images)
+	if err != nil {
+		return err
+	}
+
+	result.ImagesAdded = len(added)
+	result.ImagesSkipped = len(images) - len(added)
+
+	paths, tags := []string{}, []string{}
+	for _, image := range images {
+		result.ImagePaths = append(result.ImagePaths, image.Path)
+		if !added[image.Path] {
+			continue
+		}
+		// Repeated paths only keep the tags of the one that was saved.
+		delete(added, image.Path)
+		for _, tag := range image.Tags {
+			paths = append(paths, image.Path)
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if err := createTags(ctx, tx, tags); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO collections_images_tags (img_path, collection_id, tag_id)
+	SELECT u.img_path, $1, t.id
+	FROM unnest($2::text[], $3::text[]) AS u(img_path, tag)
+	JOIN tags AS t ON t.name = u.tag
+	ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, collectionID, pq.Array(paths), pq.Array(tags)); err != nil {
+		log.Printf("error creating record: %v", err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
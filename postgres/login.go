@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/lib/pq"
+)
+
+type LoginAttemptService struct {
+	db *DB
+}
+
+func NewLoginAttemptService(db *DB) *LoginAttemptService {
+	return &LoginAttemptService{db}
+}
+
+func (ls *LoginAttemptService) LockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	var lockedUntil *time.Time
+
+	query := `
+	SELECT MAX(locked_until)
+	FROM login_attempts
+	WHERE key = ANY($1) AND locked_until > NOW()`
+
+	if err := ls.db.QueryRowxContext(ctx, query, pq.Array(keys)).Scan(&lockedUntil); err != nil {
+		log.Println(err)
+		return time.Time{}, app.ErrInternal
+	}
+
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+
+	return *lockedUntil, nil
+}
+
+func (ls *LoginAttemptService) RecordLoginFailure(ctx context.Context, key string, policy app.LockoutPolicy) (time.Time, error) {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return time.Time{}, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	// Failures older than the policy window no longer count, so the counter
+	// starts over instead of carrying an old streak forward.
+	query := `
+	INSERT INTO login_attempts (key, failures, last_failure_at)
+	VALUES ($1, 1, NOW())
+	ON CONFLICT (key) DO UPDATE
+	SET failures = CASE
+			WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+			ELSE login_attempts.failures + 1
+		END,
+		last_failure_at = NOW()
+	RETURNING failures`
+
+	var failures int
+	if err := tx.QueryRowxContext(ctx, query, key, policy.Window.Seconds()).Scan(&failures); err != nil {
+		log.Println(err)
+		return time.Time{}, app.ErrInternal
+	}
+
+	var lockedUntil time.Time
+	if delay := policy.LockDuration(failures); delay > 0 {
+		lockedUntil = time.Now().Add(delay)
+
+		query = `
+		UPDATE login_attempts
+		SET locked_until = $2
+		WHERE key = $1`
+
+		if _, err := tx.ExecContext(ctx, query, key, lockedUntil); err != nil {
+			log.Println(err)
+			return time.Time{}, app.ErrInternal
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return time.Time{}, app.ErrInternal
+	}
+
+	return lockedUntil, nil
+}
+
+func (ls *LoginAttemptService) ResetLoginFailures(ctx context.Context, key string) error {
+	query := `
+	DELETE
+	FROM login_attempts
+	WHERE key = $1`
+
+	if _, err := ls.db.ExecContext(ctx, query, key); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+)
+
+func (cs *CollectionService) CollectionMembers(ctx context.Context, collectionID int) ([]*app.CollectionMember, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	members, err := findCollectionMembers(ctx, tx, collectionID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, tx.Commit()
+}
+
+func (cs *CollectionService) CollectionMember(ctx context.Context, collectionID int, userID int) (*app.CollectionMember, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	members, err := findCollectionMembers(ctx, tx, collectionID, &userID)
+	if err != nil {
+		return nil, err
+	} else if len(members) == 0 {
+		return nil, app.ErrNotFound
+	}
+
+	return members[0], tx.Commit()
+}
+
+func (cs *CollectionService) AddCollectionMember(ctx context.Context, member *app.CollectionMember) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO collection_members (collection_id, user_id, role)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (collection_id, user_id) DO NOTHING
+	RETURNING created_at, updated_at`
+	args := []interface{}{member.CollectionID, member.UserID, member.Role}
+
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&member.CreatedAt, &member.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return app.ErrDuplicateMember
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (cs *CollectionService) UpdateCollectionMember(ctx context.Context, member *app.CollectionMember) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE collection_members
+	SET role = $3
+	WHERE collection_id = $1 AND user_id = $2
+	RETURNING updated_at`
+	args := []interface{}{member.CollectionID, member.UserID, member.Role}
+
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&member.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return app.ErrNotFound
+		}
+		log.Printf("error updating record: %v", err)
+		return app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (cs *CollectionService) RemoveCollectionMember(ctx context.Context, collectionID int, userID int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	DELETE
+	FROM collection_members
+	WHERE collection_id = $1 AND user_id = $2`
+
+	res, err := tx.ExecContext(ctx, query, collectionID, userID)
+	if err != nil {
+		log.Printf("error deleting record: %v", err)
+		return app.ErrInternal
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	} else if n == 0 {
+		return app.ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func findCollectionMembers(ctx context.Context, tx *sqlx.Tx, collectionID int, userID *int) ([]*app.CollectionMember, error) {
+	where, args := []string{"m.collection_id = $1"}, []interface{}{collectionID}
+
+	if userID != nil {
+		where, args = append(where, "m.user_id = $2"), append(args, *userID)
+	}
+
+	query := `
+	SELECT m.collection_id, m.user_id, u.email, m.role, m.created_at, m.updated_at
+	FROM collection_members m
+	JOIN users u ON u.id = m.user_id` + formatWhereClause(where) + `
+	ORDER BY m.created_at ASC`
+
+	members := []*app.CollectionMember{}
+	if err := tx.SelectContext(ctx, &members, query, args...); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
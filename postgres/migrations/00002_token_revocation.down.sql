@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS revoked_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
+
+COMMIT;
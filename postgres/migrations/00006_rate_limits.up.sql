@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS rate_limit_buckets(
+    key VARCHAR(320) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
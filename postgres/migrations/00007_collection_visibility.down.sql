@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_collections_author_visibility;
+
+ALTER TABLE collections DROP COLUMN IF EXISTS visibility;
+
+COMMIT;
@@ -0,0 +1,6 @@
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'private';
+
+ALTER TABLE collections ADD CONSTRAINT collections_visibility_check
+    CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+CREATE INDEX idx_collections_author_visibility ON collections (author_id, visibility);
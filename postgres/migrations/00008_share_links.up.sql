@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS collection_share_links(
+    id SERIAL PRIMARY KEY,
+    collection_id INTEGER NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    permission VARCHAR(8) NOT NULL DEFAULT 'read',
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT share_links_permission_check CHECK (permission IN ('read', 'edit')),
+    CONSTRAINT fk_collection FOREIGN KEY (collection_id) REFERENCES collections (id) ON DELETE CASCADE,
+    CONSTRAINT fk_user FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_share_links_collection_id ON collection_share_links (collection_id);
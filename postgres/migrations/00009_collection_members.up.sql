@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS collection_members(
+    collection_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(8) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, user_id),
+    CONSTRAINT collection_members_role_check CHECK (role IN ('viewer', 'editor', 'owner')),
+    CONSTRAINT fk_collection FOREIGN KEY (collection_id) REFERENCES collections (id) ON DELETE CASCADE,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_collection_members_user_id ON collection_members (user_id);
+
+CREATE TRIGGER update_collection_members_updated_at BEFORE UPDATE
+ON collection_members FOR EACH ROW EXECUTE PROCEDURE
+update_updated_at_column();
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_collections_images_position;
+
+ALTER TABLE collections_images DROP COLUMN IF EXISTS position;
+
+COMMIT;
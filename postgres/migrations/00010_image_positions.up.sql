@@ -0,0 +1,14 @@
+ALTER TABLE collections_images ADD COLUMN IF NOT EXISTS position INTEGER;
+
+UPDATE collections_images AS ci
+SET position = ordered.position
+FROM (
+    SELECT img_path, collection_id,
+        ROW_NUMBER() OVER (PARTITION BY collection_id ORDER BY created_at, img_path) - 1 AS position
+    FROM collections_images
+) AS ordered
+WHERE ci.img_path = ordered.img_path AND ci.collection_id = ordered.collection_id;
+
+ALTER TABLE collections_images ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX idx_collections_images_position ON collections_images (collection_id, position);
@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE collections_images
+    DROP CONSTRAINT IF EXISTS collections_images_dimensions_check,
+    DROP COLUMN IF EXISTS caption,
+    DROP COLUMN IF EXISTS alt_text,
+    DROP COLUMN IF EXISTS source_url,
+    DROP COLUMN IF EXISTS attribution,
+    DROP COLUMN IF EXISTS width,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS dominant_color;
+
+-- img_path is left at VARCHAR(1024): shrinking it would fail or truncate
+-- paths saved after the upgrade.
+
+COMMIT;
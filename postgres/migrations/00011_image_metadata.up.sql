@@ -0,0 +1,11 @@
+ALTER TABLE collections_images ALTER COLUMN img_path TYPE VARCHAR(1024);
+
+ALTER TABLE collections_images
+    ADD COLUMN IF NOT EXISTS caption VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS alt_text VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source_url VARCHAR(2048) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS attribution VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS width INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS height INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS dominant_color VARCHAR(7) NOT NULL DEFAULT '',
+    ADD CONSTRAINT collections_images_dimensions_check CHECK (width >= 0 AND height >= 0);
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS collections_images_tags;
+
+DROP TABLE IF EXISTS collections_tags;
+
+DROP TABLE IF EXISTS tags;
+
+COMMIT;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS tags(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_tags_name_pattern ON tags (name text_pattern_ops);
+
+CREATE TABLE IF NOT EXISTS collections_tags(
+    collection_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, tag_id),
+    CONSTRAINT fk_collection FOREIGN KEY (collection_id) REFERENCES collections (id) ON DELETE CASCADE,
+    CONSTRAINT fk_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_collections_tags_tag_id ON collections_tags (tag_id);
+
+CREATE TABLE IF NOT EXISTS collections_images_tags(
+    img_path VARCHAR(1024) NOT NULL,
+    collection_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, img_path, tag_id),
+    CONSTRAINT fk_image FOREIGN KEY (img_path, collection_id)
+        REFERENCES collections_images (img_path, collection_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_collections_images_tags_tag_id ON collections_images_tags (tag_id);
@@ -0,0 +1,25 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS refresh_collections_search_vector ON collections_images;
+
+DROP TRIGGER IF EXISTS update_collections_search_vector ON collections;
+
+DROP TRIGGER IF EXISTS update_collections_images_search_vector ON collections_images;
+
+DROP FUNCTION IF EXISTS refresh_collection_search_vector();
+
+DROP FUNCTION IF EXISTS update_collection_search_vector();
+
+DROP FUNCTION IF EXISTS collection_search_vector(INTEGER, TEXT, TEXT);
+
+DROP FUNCTION IF EXISTS update_image_search_vector();
+
+DROP INDEX IF EXISTS idx_collections_images_search_vector;
+
+DROP INDEX IF EXISTS idx_collections_search_vector;
+
+ALTER TABLE collections DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE collections_images DROP COLUMN IF EXISTS search_vector;
+
+COMMIT;
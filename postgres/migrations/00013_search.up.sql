@@ -0,0 +1,69 @@
+ALTER TABLE collections_images ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION update_image_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector = to_tsvector('english', COALESCE(NEW.caption, ''));
+    RETURN NEW;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER update_collections_images_search_vector BEFORE INSERT OR UPDATE OF caption
+ON collections_images FOR EACH ROW EXECUTE PROCEDURE
+update_image_search_vector();
+
+-- The collection vector weighs its name over its description over the
+-- captions of its images.
+CREATE OR REPLACE FUNCTION collection_search_vector(c_id INTEGER, c_name TEXT, c_description TEXT)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('english', COALESCE(c_name, '')), 'A')
+        || setweight(to_tsvector('english', COALESCE(c_description, '')), 'B')
+        || setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(caption, ' ') FROM collections_images WHERE collection_id = c_id), ''
+        )), 'C');
+$$ language sql STABLE;
+
+CREATE OR REPLACE FUNCTION update_collection_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector = collection_search_vector(NEW.id, NEW.name, NEW.description);
+    RETURN NEW;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER update_collections_search_vector BEFORE INSERT OR UPDATE OF name, description
+ON collections FOR EACH ROW EXECUTE PROCEDURE
+update_collection_search_vector();
+
+CREATE OR REPLACE FUNCTION refresh_collection_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE collections
+        SET search_vector = collection_search_vector(id, name, description)
+        WHERE id = OLD.collection_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND (TG_OP = 'INSERT' OR NEW.collection_id <> OLD.collection_id) THEN
+        UPDATE collections
+        SET search_vector = collection_search_vector(id, name, description)
+        WHERE id = NEW.collection_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER refresh_collections_search_vector AFTER INSERT OR DELETE OR UPDATE OF caption, collection_id
+ON collections_images FOR EACH ROW EXECUTE PROCEDURE
+refresh_collection_search_vector();
+
+UPDATE collections_images SET search_vector = to_tsvector('english', caption);
+
+UPDATE collections SET search_vector = collection_search_vector(id, name, description);
+
+CREATE INDEX idx_collections_search_vector ON collections USING GIN (search_vector);
+
+CREATE INDEX idx_collections_images_search_vector ON collections_images USING GIN (search_vector);
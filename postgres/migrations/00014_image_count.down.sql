@@ -0,0 +1,17 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_collections_image_count_id;
+
+DROP INDEX IF EXISTS idx_collections_updated_at_id;
+
+DROP INDEX IF EXISTS idx_collections_created_at_id;
+
+DROP INDEX IF EXISTS idx_collections_name_id;
+
+DROP TRIGGER IF EXISTS count_collections_images ON collections_images;
+
+DROP FUNCTION IF EXISTS count_collection_images();
+
+ALTER TABLE collections DROP COLUMN IF EXISTS image_count;
+
+COMMIT;
@@ -0,0 +1,29 @@
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS image_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE collections AS c
+SET image_count = (SELECT COUNT(*) FROM collections_images WHERE collection_id = c.id);
+
+CREATE OR REPLACE FUNCTION count_collection_images()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE collections SET image_count = image_count - 1 WHERE id = OLD.collection_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE collections SET image_count = image_count + 1 WHERE id = NEW.collection_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER count_collections_images AFTER INSERT OR DELETE OR UPDATE OF collection_id
+ON collections_images FOR EACH ROW EXECUTE PROCEDURE
+count_collection_images();
+
+-- Keyset pagination compares (sort column, id) pairs.
+CREATE INDEX idx_collections_name_id ON collections (name, id);
+CREATE INDEX idx_collections_created_at_id ON collections (created_at, id);
+CREATE INDEX idx_collections_updated_at_id ON collections (updated_at, id);
+CREATE INDEX idx_collections_image_count_id ON collections (image_count, id);
@@ -0,0 +1,14 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS count_collections_forks ON collections;
+
+DROP FUNCTION IF EXISTS count_collection_forks();
+
+DROP INDEX IF EXISTS idx_collections_forked_from;
+
+ALTER TABLE collections
+    DROP CONSTRAINT IF EXISTS fk_forked_from,
+    DROP COLUMN IF EXISTS fork_count,
+    DROP COLUMN IF EXISTS forked_from;
+
+COMMIT;
@@ -0,0 +1,25 @@
+ALTER TABLE collections
+    ADD COLUMN IF NOT EXISTS forked_from INTEGER,
+    ADD COLUMN IF NOT EXISTS fork_count INTEGER NOT NULL DEFAULT 0,
+    ADD CONSTRAINT fk_forked_from FOREIGN KEY (forked_from) REFERENCES collections (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_collections_forked_from ON collections (forked_from);
+
+CREATE OR REPLACE FUNCTION count_collection_forks()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.forked_from IS NOT NULL THEN
+        UPDATE collections SET fork_count = fork_count - 1 WHERE id = OLD.forked_from;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.forked_from IS NOT NULL THEN
+        UPDATE collections SET fork_count = fork_count + 1 WHERE id = NEW.forked_from;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER count_collections_forks AFTER INSERT OR DELETE OR UPDATE OF forked_from
+ON collections FOR EACH ROW EXECUTE PROCEDURE
+count_collection_forks();
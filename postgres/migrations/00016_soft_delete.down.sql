@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Trashed collections would otherwise come back to life and may clash with
+-- the names of live ones.
+DELETE FROM collections WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_collections_deleted_at;
+
+DROP INDEX IF EXISTS unique_name_author;
+
+ALTER TABLE collections ADD CONSTRAINT unique_name_author UNIQUE (author_id, name);
+
+ALTER TABLE collections DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
@@ -0,0 +1,9 @@
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Names only need to be unique among collections that are not in the
+-- trash. The index keeps the constraint's name so errors read the same.
+ALTER TABLE collections DROP CONSTRAINT IF EXISTS unique_name_author;
+
+CREATE UNIQUE INDEX unique_name_author ON collections (author_id, name) WHERE deleted_at IS NULL;
+
+CREATE INDEX idx_collections_deleted_at ON collections (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS collection_revisions(
+    collection_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    actor_id INTEGER,
+    action VARCHAR(32) NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, revision),
+    CONSTRAINT fk_collection FOREIGN KEY (collection_id) REFERENCES collections (id) ON DELETE CASCADE,
+    CONSTRAINT fk_actor FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE SET NULL
+);
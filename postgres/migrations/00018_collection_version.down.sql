@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE collections DROP COLUMN IF EXISTS version;
+
+COMMIT;
@@ -0,0 +1,33 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS record_collections_images_tombstone ON collections_images;
+
+DROP TRIGGER IF EXISTS record_collections_tombstone ON collections;
+
+DROP TRIGGER IF EXISTS touch_collections_images_tags ON collections_images_tags;
+
+DROP TRIGGER IF EXISTS update_collections_images_change_seq ON collections_images;
+
+DROP TRIGGER IF EXISTS update_collections_change_seq ON collections;
+
+DROP FUNCTION IF EXISTS record_image_tombstone();
+
+DROP FUNCTION IF EXISTS record_collection_tombstone();
+
+DROP FUNCTION IF EXISTS touch_tagged_image();
+
+DROP FUNCTION IF EXISTS next_change_seq();
+
+DROP TABLE IF EXISTS sync_tombstones;
+
+DROP INDEX IF EXISTS idx_collections_images_change_seq;
+
+DROP INDEX IF EXISTS idx_collections_author_change_seq;
+
+ALTER TABLE collections_images DROP COLUMN IF EXISTS change_seq;
+
+ALTER TABLE collections DROP COLUMN IF EXISTS change_seq;
+
+DROP SEQUENCE IF EXISTS sync_seq;
+
+COMMIT;
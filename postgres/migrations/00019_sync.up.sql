@@ -0,0 +1,94 @@
+-- Every change to a collection or image takes the next number of a single
+-- sequence, so clients can ask for everything that changed after the last
+-- number they saw.
+CREATE SEQUENCE IF NOT EXISTS sync_seq;
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('sync_seq');
+
+ALTER TABLE collections_images ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('sync_seq');
+
+CREATE OR REPLACE FUNCTION next_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('sync_seq');
+    RETURN NEW;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER update_collections_change_seq BEFORE UPDATE
+ON collections FOR EACH ROW EXECUTE PROCEDURE
+next_change_seq();
+
+CREATE TRIGGER update_collections_images_change_seq BEFORE UPDATE
+ON collections_images FOR EACH ROW EXECUTE PROCEDURE
+next_change_seq();
+
+-- Image tags are part of the image, so changing them touches the image and
+-- the trigger above gives it a new number.
+CREATE OR REPLACE FUNCTION touch_tagged_image()
+RETURNS TRIGGER AS $$
+DECLARE
+    tag_row collections_images_tags%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        tag_row = OLD;
+    ELSE
+        tag_row = NEW;
+    END IF;
+
+    UPDATE collections_images SET change_seq = change_seq
+    WHERE collection_id = tag_row.collection_id AND img_path = tag_row.img_path;
+
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER touch_collections_images_tags AFTER INSERT OR DELETE
+ON collections_images_tags FOR EACH ROW EXECUTE PROCEDURE
+touch_tagged_image();
+
+-- Deleted rows leave a tombstone behind. An image tombstone has an img_path,
+-- a collection tombstone does not.
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    seq BIGINT PRIMARY KEY DEFAULT nextval('sync_seq'),
+    author_id INTEGER NOT NULL,
+    collection_id INTEGER NOT NULL,
+    img_path VARCHAR(1024),
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_sync_tombstones_author_seq ON sync_tombstones (author_id, seq);
+
+CREATE OR REPLACE FUNCTION record_collection_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (author_id, collection_id)
+    VALUES (OLD.author_id, OLD.id);
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER record_collections_tombstone AFTER DELETE
+ON collections FOR EACH ROW EXECUTE PROCEDURE
+record_collection_tombstone();
+
+-- Images deleted along with their collection find no author and leave no
+-- tombstone of their own: the collection's covers them.
+CREATE OR REPLACE FUNCTION record_image_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (author_id, collection_id, img_path)
+    SELECT author_id, id, OLD.img_path
+    FROM collections
+    WHERE id = OLD.collection_id;
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER record_collections_images_tombstone AFTER DELETE
+ON collections_images FOR EACH ROW EXECUTE PROCEDURE
+record_image_tombstone();
+
+CREATE INDEX idx_collections_author_change_seq ON collections (author_id, change_seq);
+
+CREATE INDEX idx_collections_images_change_seq ON collections_images (collection_id, change_seq);
@@ -0,0 +1,53 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_sync_tombstones_collection_change;
+
+DROP INDEX IF EXISTS idx_sync_tombstones_user_change;
+
+DROP TRIGGER IF EXISTS record_collection_members_tombstone ON collection_members;
+
+DROP FUNCTION IF EXISTS record_member_tombstone();
+
+ALTER TABLE sync_tombstones RENAME COLUMN user_id TO author_id;
+
+CREATE INDEX idx_sync_tombstones_author_seq ON sync_tombstones (author_id, seq);
+
+CREATE OR REPLACE FUNCTION record_collection_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (author_id, collection_id)
+    VALUES (OLD.author_id, OLD.id);
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE OR REPLACE FUNCTION record_image_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (author_id, collection_id, img_path)
+    SELECT author_id, id, OLD.img_path
+    FROM collections
+    WHERE id = OLD.collection_id;
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+ALTER TABLE collection_members DROP COLUMN IF EXISTS change_xid;
+
+ALTER TABLE collection_members DROP COLUMN IF EXISTS change_seq;
+
+CREATE OR REPLACE FUNCTION next_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('sync_seq');
+    RETURN NEW;
+END;
+$$ language plpgsql;
+
+ALTER TABLE sync_tombstones DROP COLUMN IF EXISTS change_xid;
+
+ALTER TABLE collections_images DROP COLUMN IF EXISTS change_xid;
+
+ALTER TABLE collections DROP COLUMN IF EXISTS change_xid;
+
+COMMIT;
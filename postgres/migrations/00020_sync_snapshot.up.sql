@@ -0,0 +1,66 @@
+-- Sequence numbers are taken when a row is written, not when its
+-- transaction commits, so a change can show up after later numbers were
+-- already synced. Rows also record the transaction that wrote them: sync
+-- only reads changes of transactions older than every one still running,
+-- ordered by transaction first.
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+ALTER TABLE collections_images ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+ALTER TABLE sync_tombstones ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE OR REPLACE FUNCTION next_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('sync_seq');
+    NEW.change_xid = pg_current_xact_id();
+    RETURN NEW;
+END;
+$$ language plpgsql;
+
+-- Members sync the collections shared with them. Joining counts as a change
+-- to the whole collection, leaving leaves a tombstone for the member.
+ALTER TABLE collection_members ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('sync_seq');
+
+ALTER TABLE collection_members ADD COLUMN IF NOT EXISTS change_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+ALTER TABLE sync_tombstones RENAME COLUMN author_id TO user_id;
+
+CREATE OR REPLACE FUNCTION record_collection_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (user_id, collection_id)
+    VALUES (OLD.author_id, OLD.id);
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE OR REPLACE FUNCTION record_image_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (user_id, collection_id, img_path)
+    SELECT author_id, id, OLD.img_path
+    FROM collections
+    WHERE id = OLD.collection_id;
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE OR REPLACE FUNCTION record_member_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (user_id, collection_id)
+    VALUES (OLD.user_id, OLD.collection_id);
+    RETURN NULL;
+END;
+$$ language plpgsql;
+
+CREATE TRIGGER record_collection_members_tombstone AFTER DELETE
+ON collection_members FOR EACH ROW EXECUTE PROCEDURE
+record_member_tombstone();
+
+DROP INDEX IF EXISTS idx_sync_tombstones_author_seq;
+
+CREATE INDEX idx_sync_tombstones_user_change ON sync_tombstones (user_id, change_xid, seq);
+
+CREATE INDEX idx_sync_tombstones_collection_change ON sync_tombstones (collection_id, change_xid, seq);
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+// staleBucketAge is how long an untouched bucket is kept. It has to outlast
+// the longest rate limit period, after which a bucket is full anyway.
+const staleBucketAge = 24 * time.Hour
+
+// RateLimiter keeps token buckets in Postgres so every instance shares the
+// same limits.
+type RateLimiter struct {
+	db *DB
+}
+
+func NewRateLimiter(db *DB) *RateLimiter {
+	return &RateLimiter{db}
+}
+
+func (rl *RateLimiter) Allow(ctx context.Context, key string, limit app.RateLimit) (app.RateLimitResult, error) {
+	tx, err := rl.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.RateLimitResult{}, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	// Every instance measures time with the database clock so skewed hosts
+	// cannot hand out extra tokens.
+	var now time.Time
+	if err := tx.QueryRowxContext(ctx, `SELECT NOW()`).Scan(&now); err != nil {
+		log.Println(err)
+		return app.RateLimitResult{}, app.ErrInternal
+	}
+
+	query := `
+	INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (key) DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, key, float64(limit.Limit), now); err != nil {
+		log.Println(err)
+		return app.RateLimitResult{}, app.ErrInternal
+	}
+
+	query = `
+	SELECT tokens, updated_at
+	FROM rate_limit_buckets
+	WHERE key = $1
+	FOR UPDATE`
+
+	bucket := &app.TokenBucket{}
+	if err := tx.GetContext(ctx, bucket, query, key); err != nil {
+		log.Println(err)
+		return app.RateLimitResult{}, app.ErrInternal
+	}
+
+	result := bucket.Take(limit, now)
+
+	query = `
+	UPDATE rate_limit_buckets
+	SET tokens = $2, updated_at = $3
+	WHERE key = $1`
+
+	if _, err := tx.ExecContext(ctx, query, key, bucket.Tokens, bucket.UpdatedAt); err != nil {
+		log.Println(err)
+		return app.RateLimitResult{}, app.ErrInternal
+	}
+
+	// Pruning on a small share of requests keeps the table bounded without
+	// a separate job.
+	if rand.IntN(1000) == 0 {
+		query = `
+		DELETE
+		FROM rate_limit_buckets
+		WHERE updated_at < $1`
+
+		if _, err := tx.ExecContext(ctx, query, now.Add(-staleBucketAge)); err != nil {
+			log.Println(err)
+			return app.RateLimitResult{}, app.ErrInternal
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.RateLimitResult{}, app.ErrInternal
+	}
+
+	return result, nil
+}
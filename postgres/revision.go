@@ -0,0 +1,313 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type revisionRow struct {
+	CollectionID int                `db:"collection_id"`
+	Revision     int                `db:"revision"`
+	ActorID      *int               `db:"actor_id"`
+	Action       app.RevisionAction `db:"action"`
+	Snapshot     []byte             `db:"snapshot"`
+	CreatedAt    time.Time          `db:"created_at"`
+}
+
+func (row *revisionRow) revision() (*app.CollectionRevision, error) {
+	revision := &app.CollectionRevision{
+		CollectionID: row.CollectionID,
+		Revision:     row.Revision,
+		ActorID:      row.ActorID,
+		Action:       row.Action,
+		CreatedAt:    row.CreatedAt,
+	}
+
+	if row.Snapshot != nil {
+		revision.Snapshot = &app.CollectionSnapshot{}
+		if err := json.Unmarshal(row.Snapshot, revision.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+func (cs *CollectionService) CollectionRevisions(ctx context.Context, collectionID int, limit int, offset int) ([]*app.CollectionRevision, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	SELECT collection_id, revision, actor_id, action, created_at
+	FROM collection_revisions
+	WHERE collection_id = $1
+	ORDER BY revision DESC` + formatLimitOffset(limit, offset)
+
+	rows := []*revisionRow{}
+	if err := tx.SelectContext(ctx, &rows, query, collectionID); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	revisions := make([]*app.CollectionRevision, len(rows))
+	for i, row := range rows {
+		if revisions[i], err = row.revision(); err != nil {
+			log.Println(err)
+			return nil, app.ErrInternal
+		}
+	}
+
+	return revisions, tx.Commit()
+}
+
+func (cs *CollectionService) CollectionRevision(ctx context.Context, collectionID int, revision int) (*app.CollectionRevision, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rev, err := findRevision(ctx, tx, collectionID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return rev, tx.Commit()
+}
+
+func (cs *CollectionService) RestoreRevision(ctx context.Context, collectionID int, revision int, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, collectionID, version); err != nil {
+		return err
+	}
+
+	rev, err := findRevision(ctx, tx, collectionID, revision)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreSnapshot(ctx, tx, collectionID, rev.Snapshot); err != nil {
+		return err
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: collectionID}); err != nil {
+		return err
+	}
+
+	if err := recordRevision(ctx, tx, collectionID, app.RevisionRestore); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func findRevision(ctx context.Context, tx *sqlx.Tx, collectionID int, revision int) (*app.CollectionRevision, error) {
+	query := `
+	SELECT collection_id, revision, actor_id, action, snapshot, created_at
+	FROM collection_revisions
+	WHERE collection_id = $1 AND revision = $2`
+
+	row := &revisionRow{}
+	if err := tx.GetContext(ctx, row, query, collectionID, revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	rev, err := row.revision()
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return rev, nil
+}
+
+// recordRevision snapshots the collection as it is at this point of the
+// transaction, attributing the change to the actor in ctx.
+func recordRevision(ctx context.Context, tx *sqlx.Tx, collectionID int, action app.RevisionAction) error {
+	// Revision numbers are taken under the collection lock.
+	if err := lockCollection(ctx, tx, collectionID); err != nil {
+		log.Printf("error locking collection: %v", err)
+		return app.ErrInternal
+	}
+
+	collection, err := findCollectionByID(ctx, tx, collectionID)
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	snapshot, err := json.Marshal(collection.Snapshot())
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	query := `
+	INSERT INTO collection_revisions (collection_id, revision, actor_id, action, snapshot)
+	SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4
+	FROM collection_revisions
+	WHERE collection_id = $1`
+
+	args := []interface{}{collectionID, app.ActorFromContext(ctx), action, snapshot}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		log.Printf("error creating record: %v", err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+// restoreSnapshot makes the collection, its images and tags match the
+// snapshot. Images keep the time they were first saved.
+func restoreSnapshot(ctx context.Context, tx *sqlx.Tx, collectionID int, snapshot *app.CollectionSnapshot) error {
+	query := `
+	UPDATE collections
+	SET name = $1, description = $2, poster = $3, visibility = $4
+	WHERE id = $5`
+
+	args := []interface{}{snapshot.Name, snapshot.Description, snapshot.Poster, snapshot.Visibility, collectionID}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		if isDuplicateCollectionName(err) {
+			return app.ErrDuplicateName
+		}
+		log.Printf("error updating record: %v", err)
+		return app.ErrInternal
+	}
+
+	n := len(snapshot.Images)
+	paths, captions, altTexts := make([]string, n), make([]string, n), make([]string, n)
+	sourceURLs, attributions, colors := make([]string, n), make([]string, n), make([]string, n)
+	positions, widths, heights := make([]int64, n), make([]int64, n), make([]int64, n)
+	savedAt := make([]string, n)
+	imageTagPaths, imageTags := []string{}, []string{}
+	for i, image := range snapshot.Images {
+		paths[i], captions[i], altTexts[i] = image.Path, image.Caption, image.AltText
+		sourceURLs[i], attributions[i], colors[i] = image.SourceURL, image.Attribution, image.DominantColor
+		positions[i], widths[i], heights[i] = int64(image.Position), int64(image.Width), int64(image.Height)
+		savedAt[i] = image.SavedAt.Format(time.RFC3339Nano)
+
+		for _, tag := range image.Tags {
+			imageTagPaths, imageTags = append(imageTagPaths, image.Path), append(imageTags, tag)
+		}
+	}
+
+	query = `
+	DELETE
+	FROM collections_images
+	WHERE collection_id = $1 AND NOT (img_path = ANY($2))`
+
+	if _, err := tx.ExecContext(ctx, query, collectionID, pq.Array(paths)); err != nil {
+		log.Printf("error deleting records: %v", err)
+		return app.ErrInternal
+	}
+
+	query = `
+	INSERT INTO collections_images (
+		img_path, collection_id, position, caption, alt_text, source_url,
+		attribution, width, height, dominant_color, created_at
+	)
+	SELECT u.img_path, $1, u.position, u.caption, u.alt_text, u.source_url,
+		u.attribution, u.width, u.height, u.dominant_color, u.created_at
+	FROM unnest(
+		$2::text[], $3::int[], $4::text[], $5::text[], $6::text[],
+		$7::text[], $8::int[], $9::int[], $10::text[], $11::timestamptz[]
+	) AS u(img_path, position, caption, alt_text, source_url, attribution, width, height, dominant_color, created_at)
+	ON CONFLICT (img_path, collection_id) DO UPDATE
+	SET position = EXCLUDED.position,
+		caption = EXCLUDED.caption,
+		alt_text = EXCLUDED.alt_text,
+		source_url = EXCLUDED.source_url,
+		attribution = EXCLUDED.attribution,
+		width = EXCLUDED.width,
+		height = EXCLUDED.height,
+		dominant_color = EXCLUDED.dominant_color`
+
+	args = []interface{}{
+		collectionID,
+		pq.Array(paths),
+		pq.Array(positions),
+		pq.Array(captions),
+		pq.Array(altTexts),
+		pq.Array(sourceURLs),
+		pq.Array(attributions),
+		pq.Array(widths),
+		pq.Array(heights),
+		pq.Array(colors),
+		pq.Array(savedAt),
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		log.Printf("error creating records: %v", err)
+		return app.ErrInternal
+	}
+
+	if err := createTags(ctx, tx, append(append([]string{}, snapshot.Tags...), imageTags...)); err != nil {
+		return err
+	}
+
+	query = `DELETE FROM collections_tags WHERE collection_id = $1`
+	if _, err := tx.ExecContext(ctx, query, collectionID); err != nil {
+		log.Printf("error deleting records: %v", err)
+		return app.ErrInternal
+	}
+
+	query = `
+	INSERT INTO collections_tags (collection_id, tag_id)
+	SELECT $1, id FROM tags WHERE name = ANY($2)`
+
+	if _, err := tx.ExecContext(ctx, query, collectionID, pq.Array(snapshot.Tags)); err != nil {
+		log.Printf("error creating records: %v", err)
+		return app.ErrInternal
+	}
+
+	query = `DELETE FROM collections_images_tags WHERE collection_id = $1`
+	if _, err := tx.ExecContext(ctx, query, collectionID); err != nil {
+		log.Printf("error deleting records: %v", err)
+		return app.ErrInternal
+	}
+
+	query = `
+	INSERT INTO collections_images_tags (img_path, collection_id, tag_id)
+	SELECT u.img_path, $1, t.id
+	FROM unnest($2::text[], $3::text[]) AS u(img_path, name)
+	JOIN tags AS t ON t.name = u.name
+	ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, collectionID, pq.Array(imageTagPaths), pq.Array(imageTags)); err != nil {
+		log.Printf("error creating records: %v", err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
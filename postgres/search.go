@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// maxImageMatches caps how many matching images are returned per result.
+const maxImageMatches = 5
+
+// ts_headline marks matches with control characters instead of HTML, since
+// the text around them is not escaped. The characters are stripped from the
+// text first and become tags once highlightHTML has escaped it.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+
+	headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop
+)
+
+// highlightHTML escapes text highlighted by ts_headline and turns its
+// markers into <b> tags.
+func highlightHTML(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(highlightStart, "<b>", highlightStop, "</b>").Replace(s)
+}
+
+func (cs *CollectionService) SearchCollections(ctx context.Context, sq app.SearchQuery) ([]*app.SearchResult, int, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, 0, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	// Anonymous callers only see public collections. Unlisted ones are
+	// never searchable by people who do not belong to them.
+	where := `
+	FROM collections, (SELECT websearch_to_tsquery('english', $1) AS query) AS q
+	WHERE search_vector @@ q.query
+		AND deleted_at IS NULL
+		AND (
+			visibility = 'public'
+			OR author_id = $2
+			OR id IN (SELECT collection_id FROM collection_members WHERE user_id = $2)
+		)`
+
+	// Counted on its own so offsets past the last match still get it.
+	total := 0
+	if err := tx.GetContext(ctx, &total, "SELECT COUNT(*)"+where, sq.Query, sq.UserID); err != nil {
+		log.Println(err)
+		return nil, 0, app.ErrInternal
+	}
+
+	query := `
+	SELECT ` + collectionColumns + `,
+		ts_rank(search_vector, q.query) AS rank,
+		ts_headline('english', translate(name, $4, ''), q.query, $3 || ', HighlightAll=true') AS name_highlight,
+		ts_headline('english', translate(COALESCE(description, ''), $4, ''), q.query, $3) AS description_highlight` + where + `
+	ORDER BY rank DESC, id ASC` + formatLimitOffset(sq.Limit, sq.Offset)
+
+	rows := []*struct {
+		app.Collection
+		Rank                 float64 `db:"rank"`
+		NameHighlight        string  `db:"name_highlight"`
+		DescriptionHighlight string  `db:"description_highlight"`
+	}{}
+	args := []interface{}{sq.Query, sq.UserID, headlineOptions, highlightStart + highlightStop}
+	if err := tx.SelectContext(ctx, &rows, query, args...); err != nil {
+		log.Println(err)
+		return nil, 0, app.ErrInternal
+	}
+
+	results := make([]*app.SearchResult, len(rows))
+	collections := make([]*app.Collection, len(rows))
+	for i, row := range rows {
+		collection := row.Collection
+		collections[i] = &collection
+		results[i] = &app.SearchResult{
+			Collection: &collection,
+			Rank:       row.Rank,
+			Highlights: app.SearchHighlights{
+				Name:        highlightHTML(row.NameHighlight),
+				Description: highlightHTML(row.DescriptionHighlight),
+			},
+		}
+	}
+
+	if err := attachCollectionTags(ctx, tx, collections); err != nil {
+		log.Println(err)
+		return nil, 0, app.ErrInternal
+	}
+
+	if err := attachImageMatches(ctx, tx, sq.Query, results); err != nil {
+		log.Println(err)
+		return nil, 0, app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, 0, app.ErrInternal
+	}
+
+	return results, total, nil
+}
+
+// attachImageMatches adds the best matching images of every result with a
+// single query, their captions highlighted.
+func attachImageMatches(ctx context.Context, tx *sqlx.Tx, text string, results []*app.SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(results))
+	byID := make(map[int]*app.SearchResult, len(results))
+	for i, result := range results {
+		ids[i] = int64(result.Collection.ID)
+		byID[result.Collection.ID] = result
+	}
+
+	query := `
+	WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query)
+	SELECT collection_id, img_path, caption
+	FROM (
+		SELECT ci.collection_id, ci.img_path,
+			ts_headline('english', translate(ci.caption, $5, ''), q.query, $4) AS caption,
+			ROW_NUMBER() OVER (
+				PARTITION BY ci.collection_id
+				ORDER BY ts_rank(ci.search_vector, q.query) DESC, ci.position ASC
+			) AS n
+		FROM collections_images AS ci, q
+		WHERE ci.collection_id = ANY($2) AND ci.search_vector @@ q.query
+	) AS matches
+	WHERE n <= $3
+	ORDER BY collection_id, n`
+
+	rows := []struct {
+		CollectionID int `db:"collection_id"`
+		app.ImageMatch
+	}{}
+	args := []interface{}{text, pq.Array(ids), maxImageMatches, headlineOptions, highlightStart + highlightStop}
+	if err := tx.SelectContext(ctx, &rows, query, args...); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if result, ok := byID[row.CollectionID]; ok {
+			match := row.ImageMatch
+			match.Caption = highlightHTML(match.Caption)
+			result.Images = append(result.Images, &match)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import "testing"
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Film posters", "Film posters"},
+		{"match", "Film \x02posters\x03", "Film <b>posters</b>"},
+		{"script", "<script>alert(1)</script> \x02posters\x03", "&lt;script&gt;alert(1)&lt;/script&gt; <b>posters</b>"},
+		{"markup in match", "\x02<b>posters</b>\x03", "<b>&lt;b&gt;posters&lt;/b&gt;</b>"},
+		{"attribute", `"><img src=x onerror=alert(1)>`, "&#34;&gt;&lt;img src=x onerror=alert(1)&gt;"},
+		{"entities", "Tom & Jerry", "Tom &amp; Jerry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightHTML(tt.in); got != tt.want {
+				t.Errorf("highlightHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
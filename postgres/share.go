@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+)
+
+func (cs *CollectionService) CreateShareLink(ctx context.Context, link *app.ShareLink) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO collection_share_links (collection_id, token_hash, permission, expires_at, created_by)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+	`
+	args := []interface{}{link.CollectionID, link.TokenHash, link.Permission, link.ExpiresAt, link.CreatedBy}
+
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&link.ID, &link.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (cs *CollectionService) ShareLinks(ctx context.Context, collectionID int) ([]*app.ShareLink, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	SELECT *
+	FROM collection_share_links
+	WHERE collection_id = $1
+	ORDER BY created_at DESC`
+
+	links := []*app.ShareLink{}
+	if err := tx.SelectContext(ctx, &links, query, collectionID); err != nil {
+		return nil, err
+	}
+
+	return links, tx.Commit()
+}
+
+func (cs *CollectionService) RevokeShareLink(ctx context.Context, collectionID int, id int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE collection_share_links
+	SET revoked_at = COALESCE(revoked_at, NOW())
+	WHERE id = $1 AND collection_id = $2`
+
+	res, err := tx.ExecContext(ctx, query, id, collectionID)
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	} else if n == 0 {
+		return app.ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (cs *CollectionService) CollectionByShareToken(ctx context.Context, token string) (*app.Collection, *app.ShareLink, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer tx.Rollback()
+
+	link, err := findActiveShareLink(ctx, tx, app.HashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collection, err := findCollectionByID(ctx, tx, link.CollectionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return collection, link, nil
+}
+
+func findActiveShareLink(ctx context.Context, tx *sqlx.Tx, hash string) (*app.ShareLink, error) {
+	query := `
+	SELECT *
+	FROM collection_share_links
+	WHERE token_hash = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())`
+
+	link := &app.ShareLink{}
+	if err := tx.GetContext(ctx, link, query, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return link, nil
+}
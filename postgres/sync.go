@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"slices"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+)
+
+type syncCollectionRow struct {
+	app.Collection
+	app.SyncCursor
+}
+
+type syncImageRow struct {
+	app.Image
+	app.SyncCursor
+}
+
+type tombstoneRow struct {
+	app.SyncCursor
+	CollectionID int     `db:"collection_id"`
+	Path         *string `db:"img_path"`
+}
+
+// CollectionChanges reads the changed collections, images and tombstones
+// separately, limit of each, and keeps the first limit of them all by
+// cursor. The reads share a snapshot so the three agree.
+func (cs *CollectionService) CollectionChanges(ctx context.Context, userID int, since app.SyncCursor, limit int) (*app.SyncChanges, error) {
+	tx, err := cs.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	collections, images, tombstones, err := findChanges(ctx, tx, userID, since, limit+1)
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	cursors := make([]app.SyncCursor, 0, len(collections)+len(images)+len(tombstones))
+	for _, c := range collections {
+		cursors = append(cursors, c.SyncCursor)
+	}
+	for _, image := range images {
+		cursors = append(cursors, image.SyncCursor)
+	}
+	for _, t := range tombstones {
+		cursors = append(cursors, t.SyncCursor)
+	}
+	slices.SortFunc(cursors, app.SyncCursor.Compare)
+
+	changes := &app.SyncChanges{
+		Collections:        []*app.Collection{},
+		Images:             []*app.Image{},
+		DeletedCollections: []int{},
+		DeletedImages:      []*app.ImageTombstone{},
+	}
+	last := since
+	if len(cursors) > limit {
+		cursors = cursors[:limit]
+		changes.HasMore = true
+	}
+	if len(cursors) > 0 {
+		last = cursors[len(cursors)-1]
+	}
+	changes.Cursor = last.Encode()
+
+	for _, c := range collections {
+		switch {
+		case c.SyncCursor.Compare(last) > 0:
+		case c.DeletedAt != nil:
+			changes.DeletedCollections = append(changes.DeletedCollections, c.ID)
+		default:
+			collection := c.Collection
+			changes.Collections = append(changes.Collections, &collection)
+		}
+	}
+
+	ids := []int64{}
+	for _, row := range images {
+		if row.SyncCursor.Compare(last) <= 0 {
+			image := row.Image
+			changes.Images = append(changes.Images, &image)
+			ids = append(ids, int64(image.CollectionId))
+		}
+	}
+
+	for _, t := range tombstones {
+		if t.SyncCursor.Compare(last) > 0 {
+			continue
+		}
+		if t.Path == nil {
+			changes.DeletedCollections = append(changes.DeletedCollections, t.CollectionID)
+		} else {
+			changes.DeletedImages = append(changes.DeletedImages, &app.ImageTombstone{CollectionID: t.CollectionID, Path: *t.Path})
+		}
+	}
+
+	if err := attachCollectionTags(ctx, tx, changes.Collections); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	if err := attachImageTags(ctx, tx, ids, changes.Images); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return changes, nil
+}
+
+// visibleCollections lists the collections the user $1 authors or is a
+// member of, with the cursor of when they joined. Authors have always been
+// there.
+const visibleCollections = `
+	WITH visible AS (
+		SELECT id AS visible_id, '0'::xid8 AS joined_xid, 0::bigint AS joined_seq
+		FROM collections
+		WHERE author_id = $1
+		UNION ALL
+		SELECT collection_id, change_xid, change_seq
+		FROM collection_members
+		WHERE user_id = $1
+	)`
+
+// pastRunningTransactions keeps the changes after the cursor in $2 and $3
+// made by transactions older than every one still running. A newer change
+// could be followed by an older transaction committing a change with an
+// earlier cursor, which a client past it would never see.
+const pastRunningTransactions = `
+	(sync_xid, sync_seq) > ($2::xid8, $3)
+	AND sync_xid < pg_snapshot_xmin(pg_current_snapshot())`
+
+// findChanges returns the first limit collections, images and tombstones
+// of the user after since, each ordered by cursor. A row changes when it is
+// written or when the user joins its collection, whichever came last.
+// Images of trashed collections are left out.
+func findChanges(ctx context.Context, tx *sqlx.Tx, userID int, since app.SyncCursor, limit int) ([]*syncCollectionRow, []*syncImageRow, []*tombstoneRow, error) {
+	collections := []*syncCollectionRow{}
+	query := visibleCollections + `
+	SELECT ` + collectionColumns + `, sync_xid, sync_seq
+	FROM (
+		SELECT ` + collectionColumns + `,
+			CASE WHEN (joined_xid, joined_seq) > (change_xid, change_seq) THEN joined_xid ELSE change_xid END AS sync_xid,
+			CASE WHEN (joined_xid, joined_seq) > (change_xid, change_seq) THEN joined_seq ELSE change_seq END AS sync_seq
+		FROM collections
+		JOIN visible ON visible_id = id
+	) AS changed
+	WHERE ` + pastRunningTransactions + `
+	ORDER BY sync_xid ASC, sync_seq ASC
+	LIMIT $4`
+	if err := tx.SelectContext(ctx, &collections, query, userID, since.XID, since.Seq, limit); err != nil {
+		return nil, nil, nil, err
+	}
+
+	images := []*syncImageRow{}
+	query = visibleCollections + `
+	SELECT ` + imageColumns + `, sync_xid, sync_seq
+	FROM (
+		SELECT ` + imageColumns + `,
+			CASE WHEN (joined_xid, joined_seq) > (change_xid, change_seq) THEN joined_xid ELSE change_xid END AS sync_xid,
+			CASE WHEN (joined_xid, joined_seq) > (change_xid, change_seq) THEN joined_seq ELSE change_seq END AS sync_seq
+		FROM collections_images
+		JOIN visible ON visible_id = collection_id
+		WHERE collection_id IN (SELECT id FROM collections WHERE deleted_at IS NULL)
+	) AS changed
+	WHERE ` + pastRunningTransactions + `
+	ORDER BY sync_xid ASC, sync_seq ASC
+	LIMIT $4`
+	if err := tx.SelectContext(ctx, &images, query, userID, since.XID, since.Seq, limit); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Image tombstones are recorded for the author, members find them by
+	// collection.
+	tombstones := []*tombstoneRow{}
+	query = `
+	SELECT sync_xid, sync_seq, collection_id, img_path
+	FROM (
+		SELECT change_xid AS sync_xid, seq AS sync_seq, collection_id, img_path
+		FROM sync_tombstones
+		WHERE user_id = $1
+			OR (img_path IS NOT NULL
+				AND collection_id IN (SELECT collection_id FROM collection_members WHERE user_id = $1))
+	) AS changed
+	WHERE ` + pastRunningTransactions + `
+	ORDER BY sync_xid ASC, sync_seq ASC
+	LIMIT $4`
+	if err := tx.SelectContext(ctx, &tombstones, query, userID, since.XID, since.Seq, limit); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return collections, images, tombstones, nil
+}
@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func (cs *CollectionService) AddCollectionTags(ctx context.Context, collectionID int, tags []string, version *int) ([]string, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, collectionID, version); err != nil {
+		return nil, err
+	}
+
+	if err := createTags(ctx, tx, tags); err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO collections_tags (collection_id, tag_id)
+	SELECT $1, id FROM tags WHERE name = ANY($2)
+	ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, collectionID, pq.Array(tags)); err != nil {
+		log.Printf("error creating record: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	current, err := findCollectionTags(ctx, tx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: collectionID}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return current, nil
+}
+
+func (cs *CollectionService) RemoveCollectionTag(ctx context.Context, collectionID int, tag string, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, collectionID, version); err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM collections_tags
+	WHERE collection_id = $1 AND tag_id = (SELECT id FROM tags WHERE name = $2)`
+
+	if err := execOne(ctx, tx, query, collectionID, tag); err != nil {
+		return err
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: collectionID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (cs *CollectionService) AddImageTags(ctx context.Context, collectionID int, path string, tags []string, version *int) ([]string, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, collectionID, version); err != nil {
+		return nil, err
+	}
+
+	if _, err := findImageForUpdate(ctx, tx, collectionID, path); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	if err := createTags(ctx, tx, tags); err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO collections_images_tags (img_path, collection_id, tag_id)
+	SELECT $1, $2, id FROM tags WHERE name = ANY($3)
+	ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, path, collectionID, pq.Array(tags)); err != nil {
+		log.Printf("error creating record: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	current := []string{}
+	query = `
+	SELECT t.name
+	FROM collections_images_tags AS cit
+	JOIN tags AS t ON t.id = cit.tag_id
+	WHERE cit.collection_id = $1 AND cit.img_path = $2
+	ORDER BY t.name ASC`
+
+	if err := tx.SelectContext(ctx, &current, query, collectionID, path); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: collectionID}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return current, nil
+}
+
+func (cs *CollectionService) RemoveImageTag(ctx context.Context, collectionID int, path string, tag string, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, collectionID, version); err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM collections_images_tags
+	WHERE collection_id = $1 AND img_path = $2 AND tag_id = (SELECT id FROM tags WHERE name = $3)`
+
+	if err := execOne(ctx, tx, query, collectionID, path, tag); err != nil {
+		return err
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: collectionID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (cs *CollectionService) TagSuggestions(ctx context.Context, userID int, prefix string, limit int) ([]*app.Tag, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	SELECT t.name, COUNT(*) AS count
+	FROM tags AS t
+	JOIN (
+		SELECT tag_id, collection_id FROM collections_tags
+		UNION ALL
+		SELECT tag_id, collection_id FROM collections_images_tags
+	) AS used ON used.tag_id = t.id
+	JOIN collections AS c ON c.id = used.collection_id
+	WHERE t.name LIKE $2 || '%'
+		AND c.deleted_at IS NULL
+		AND (c.author_id = $1 OR c.id IN (SELECT collection_id FROM collection_members WHERE user_id = $1))
+	GROUP BY t.name
+	ORDER BY count DESC, t.name ASC
+	LIMIT $3`
+
+	tags := []*app.Tag{}
+	if err := tx.SelectContext(ctx, &tags, query, userID, escapeLike(prefix), limit); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return tags, tx.Commit()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func createTags(ctx context.Context, tx *sqlx.Tx, tags []string) error {
+	query := `
+	INSERT INTO tags (name)
+	SELECT unnest($1::text[])
+	ON CONFLICT (name) DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, pq.Array(tags)); err != nil {
+		log.Printf("error creating record: %v", err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+// execOne runs a statement that must affect exactly one row, returning
+// ErrNotFound when it affected none.
+func execOne(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("error executing statement: %v", err)
+		return app.ErrInternal
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	} else if n == 0 {
+		return app.ErrNotFound
+	}
+
+	return nil
+}
+
+func findCollectionTags(ctx context.Context, tx *sqlx.Tx, collectionID int) ([]string, error) {
+	tags := []string{}
+	query := `
+	SELECT t.name
+	FROM collections_tags AS ct
+	JOIN tags AS t ON t.id = ct.tag_id
+	WHERE ct.collection_id = $1
+	ORDER BY t.name ASC`
+
+	if err := tx.SelectContext(ctx, &tags, query, collectionID); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return tags, nil
+}
+
+// attachCollectionTags loads the tags of every given collection with a
+// single query.
+func attachCollectionTags(ctx context.Context, tx *sqlx.Tx, collections []*app.Collection) error {
+	if len(collections) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(collections))
+	byID := make(map[int]*app.Collection, len(collections))
+	for i, c := range collections {
+		ids[i] = int64(c.ID)
+		byID[c.ID] = c
+	}
+
+	query := `
+	SELECT ct.collection_id, t.name
+	FROM collections_tags AS ct
+	JOIN tags AS t ON t.id = ct.tag_id
+	WHERE ct.collection_id = ANY($1)
+	ORDER BY t.name ASC`
+
+	rows := []struct {
+		CollectionID int    `db:"collection_id"`
+		Name         string `db:"name"`
+	}{}
+	if err := tx.SelectContext(ctx, &rows, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if c, ok := byID[row.CollectionID]; ok {
+			c.Tags = append(c.Tags, row.Name)
+		}
+	}
+
+	return nil
+}
+
+// attachImageTags loads the tags of every given image with a single query.
+func attachImageTags(ctx context.Context, tx *sqlx.Tx, ids []int64, images []*app.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	byKey := make(map[string]*app.Image, len(images))
+	for _, image := range images {
+		byKey[imageKey(image.CollectionId, image.Path)] = image
+	}
+
+	query := `
+	SELECT cit.collection_id, cit.img_path, t.name
+	FROM collections_images_tags AS cit
+	JOIN tags AS t ON t.id = cit.tag_id
+	WHERE cit.collection_id = ANY($1)
+	ORDER BY t.name ASC`
+
+	rows := []struct {
+		CollectionID int    `db:"collection_id"`
+		Path         string `db:"img_path"`
+		Name         string `db:"name"`
+	}{}
+	if err := tx.SelectContext(ctx, &rows, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if image, ok := byKey[imageKey(row.CollectionID, row.Path)]; ok {
+			image.Tags = append(image.Tags, row.Name)
+		}
+	}
+
+	return nil
+}
+
+func imageKey(collectionID int, path string) string {
+	return fmt.Sprintf("%d/%s", collectionID, path)
+}
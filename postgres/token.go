@@ -0,0 +1,329 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+)
+
+type TokenService struct {
+	db *DB
+}
+
+func NewTokenService(db *DB) *TokenService {
+	return &TokenService{db}
+}
+
+func (ts *TokenService) CreateRefreshToken(ctx context.Context, token *app.RefreshToken) error {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := createRefreshToken(ctx, tx, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ts *TokenService) RotateRefreshToken(ctx context.Context, hash string, next *app.RefreshToken) error {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	current, err := findRefreshTokenForUpdate(ctx, tx, hash)
+	if err != nil {
+		return err
+	}
+
+	if current.RevokedAt != nil {
+		// A rotated token was presented again, so either the client or an
+		// attacker holds a stale copy. Kill every token descended from it.
+		if err := revokeTokenFamily(ctx, tx, current.FamilyID); err != nil {
+			log.Println(err)
+			return app.ErrInternal
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			return app.ErrInternal
+		}
+
+		return app.ErrTokenReused
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return app.ErrTokenExpired
+	}
+
+	if err := revokeRefreshToken(ctx, tx, current.ID); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+
+	if err := createRefreshToken(ctx, tx, next); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (ts *TokenService) RevokeRefreshToken(ctx context.Context, userID int, hash string) error {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE revoked_at IS NULL AND family_id = (
+		SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2
+	)`
+
+	if _, err := tx.ExecContext(ctx, query, hash, userID); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (ts *TokenService) RevokeAccessToken(ctx context.Context, userID int, jti string, expiresAt time.Time) error {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	// Entries are only useful until the token expires, so prune those first.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	query := `
+	INSERT INTO revoked_tokens (jti, user_id, expires_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, jti, userID, expiresAt); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (ts *TokenService) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	if err := ts.db.QueryRowxContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (ts *TokenService) RevokeUserTokens(ctx context.Context, userID int) error {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := revokeUserTokens(ctx, tx, userID); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (ts *TokenService) CreatePasswordReset(ctx context.Context, reset *app.PasswordReset) error {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO password_resets (user_id, token_hash, expires_at)
+	VALUES ($1, $2, $3) RETURNING id, created_at
+	`
+	args := []interface{}{reset.UserID, reset.TokenHash, reset.ExpiresAt}
+
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&reset.ID, &reset.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ts *TokenService) ConsumePasswordReset(ctx context.Context, hash string) (*app.PasswordReset, error) {
+	tx, err := ts.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE password_resets
+	SET used_at = NOW()
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	RETURNING *`
+
+	reset := &app.PasswordReset{}
+	if err := tx.GetContext(ctx, reset, query, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	query = `
+	UPDATE password_resets
+	SET used_at = NOW()
+	WHERE user_id = $1 AND used_at IS NULL`
+
+	if _, err := tx.ExecContext(ctx, query, reset.UserID); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return reset, nil
+}
+
+func createRefreshToken(ctx context.Context, tx *sqlx.Tx, token *app.RefreshToken) error {
+	query := `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+	VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+	args := []interface{}{token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt}
+
+	return tx.QueryRowxContext(ctx, query, args...).Scan(&token.ID, &token.CreatedAt)
+}
+
+func findRefreshTokenForUpdate(ctx context.Context, tx *sqlx.Tx, hash string) (*app.RefreshToken, error) {
+	query := `
+	SELECT *
+	FROM refresh_tokens
+	WHERE token_hash = $1
+	FOR UPDATE`
+
+	token := &app.RefreshToken{}
+	if err := tx.GetContext(ctx, token, query, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, app.ErrNotFound
+		}
+		log.Printf("error finding refresh token: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	return token, nil
+}
+
+func revokeRefreshToken(ctx context.Context, tx *sqlx.Tx, id int) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+func revokeTokenFamily(ctx context.Context, tx *sqlx.Tx, familyID string) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// revokeUserTokens bumps the user's token version, which invalidates every
+// access token carrying the old one, and revokes all their refresh tokens.
+func revokeUserTokens(ctx context.Context, tx *sqlx.Tx, userID int) error {
+	query := `
+	UPDATE users
+	SET token_version = token_version + 1
+	WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	return revokeRefreshTokens(ctx, tx, userID)
+}
+
+func revokeRefreshTokens(ctx context.Context, tx *sqlx.Tx, userID int) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"log"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func (cs *CollectionService) TransferImages(ctx context.Context, transfer app.ImageTransfer) ([]*app.ImageTransferResult, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	ids := append([]int{transfer.SourceID}, transfer.TargetIDs...)
+	if err := lockCollections(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+
+	if err := lockCollectionVersion(ctx, tx, transfer.SourceID, transfer.SourceVersion); err != nil {
+		return nil, err
+	}
+
+	found := []string{}
+	query := `
+	SELECT img_path
+	FROM collections_images
+	WHERE collection_id = $1 AND img_path = ANY($2)`
+
+	if err := tx.SelectContext(ctx, &found, query, transfer.SourceID, pq.Array(transfer.Paths)); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	added, err := copyImages(ctx, tx, transfer.SourceID, transfer.TargetIDs, found, transfer.Move)
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.Move && len(found) > 0 {
+		query := `
+		DELETE
+		FROM collections_images
+		WHERE collection_id = $1 AND img_path = ANY($2)`
+
+		if _, err := tx.ExecContext(ctx, query, transfer.SourceID, pq.Array(found)); err != nil {
+			log.Printf("error deleting records: %v", err)
+			return nil, app.ErrInternal
+		}
+	}
+
+	inSource := make(map[string]bool, len(found))
+	for _, path := range found {
+		inSource[path] = true
+	}
+
+	results := []*app.ImageTransferResult{}
+	for _, target := range transfer.TargetIDs {
+		for _, path := range transfer.Paths {
+			status := app.BatchImageAlreadyPresent
+			switch {
+			case !inSource[path]:
+				status = app.BatchImageNotFound
+			case added[imageKey(target, path)]:
+				status = app.BatchImageAdded
+			}
+			results = append(results, &app.ImageTransferResult{CollectionID: target, Path: path, Status: status})
+		}
+	}
+	if transfer.Move {
+		for _, path := range found {
+			results = append(results, &app.ImageTransferResult{
+				CollectionID: transfer.SourceID,
+				Path:         path,
+				Status:       app.BatchImageRemoved,
+			})
+		}
+	}
+
+	// Copying only reads the source, so it keeps its version.
+	changed := transfer.TargetIDs
+	if transfer.Move {
+		changed = ids
+	}
+
+	for _, id := range changed {
+		if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: id}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range transfer.TargetIDs {
+		if err := recordRevision(ctx, tx, id, app.RevisionTransferIn); err != nil {
+			return nil, err
+		}
+	}
+
+	if transfer.Move {
+		if err := recordRevision(ctx, tx, transfer.SourceID, app.RevisionTransferOut); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, app.ErrInternal
+	}
+
+	return results, nil
+}
+
+// lockCollections locks every collection in id order, so concurrent
+// transfers between the same collections cannot deadlock. It returns
+// ErrNotFound if any of them does not exist.
+func lockCollections(ctx context.Context, tx *sqlx.Tx, ids []int) error {
+	distinct := map[int]bool{}
+	for _, id := range ids {
+		distinct[id] = true
+	}
+
+	int64IDs := make([]int64, len(ids))
+	for i, id := range ids {
+		int64IDs[i] = int64(id)
+	}
+
+	locked := []int{}
+	query := `SELECT id FROM collections WHERE id = ANY($1) ORDER BY id FOR UPDATE`
+	if err := tx.SelectContext(ctx, &locked, query, pq.Array(int64IDs)); err != nil {
+		log.Printf("error locking collections: %v", err)
+		return app.ErrInternal
+	}
+
+	if len(locked) != len(distinct) {
+		return app.ErrNotFound
+	}
+
+	return nil
+}
+
+// copyImages copies the source images, with their tags, to the end of every
+// target collection and returns the imageKey of each copy it made. Images a
+// target already has are left untouched.
+func copyImages(ctx context.Context, tx *sqlx.Tx, sourceID int, targetIDs []int, paths []string, keepSavedAt bool) (map[string]bool, error) {
+	added := map[string]bool{}
+	if len(paths) == 0 || len(targetIDs) == 0 {
+		return added, nil
+	}
+
+	targets := make([]int64, len(targetIDs))
+	for i, id := range targetIDs {
+		targets[i] = int64(id)
+	}
+
+	query := `
+	INSERT INTO collections_images (
+		img_path, collection_id, position, caption, alt_text, source_url,
+		attribution, width, height, dominant_color, created_at
+	)
+	SELECT ci.img_path, t.id, base.next + ROW_NUMBER() OVER (PARTITION BY t.id ORDER BY ci.position) - 1,
+		ci.caption, ci.alt_text, ci.source_url, ci.attribution, ci.width, ci.height, ci.dominant_color,
+		CASE WHEN $4 THEN ci.created_at ELSE NOW() END
+	FROM unnest($3::int[]) AS t(id)
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(MAX(position) + 1, 0) AS next
+		FROM collections_images
+		WHERE collection_id = t.id
+	) AS base
+	JOIN collections_images AS ci ON ci.collection_id = $1 AND ci.img_path = ANY($2)
+	ON CONFLICT (img_path, collection_id) DO NOTHING
+	RETURNING collection_id, img_path`
+
+	rows := []struct {
+		CollectionID int    `db:"collection_id"`
+		Path         string `db:"img_path"`
+	}{}
+	err := tx.SelectContext(ctx, &rows, query, sourceID, pq.Array(paths), pq.Array(targets), keepSavedAt)
+	if err != nil {
+		log.Printf("error creating records: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	if len(rows) == 0 {
+		return added, nil
+	}
+
+	copiedTo, copiedPaths := make([]int64, len(rows)), make([]string, len(rows))
+	for i, row := range rows {
+		added[imageKey(row.CollectionID, row.Path)] = true
+		copiedTo[i], copiedPaths[i] = int64(row.CollectionID), row.Path
+	}
+
+	query = `
+	INSERT INTO collections_images_tags (img_path, collection_id, tag_id)
+	SELECT copied.img_path, copied.collection_id, cit.tag_id
+	FROM unnest($2::int[], $3::text[]) AS copied(collection_id, img_path)
+	JOIN collections_images_tags AS cit ON cit.collection_id = $1 AND cit.img_path = copied.img_path
+	ON CONFLICT DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, sourceID, pq.Array(copiedTo), pq.Array(copiedPaths)); err != nil {
+		log.Printf("error creating records: %v", err)
+		return nil, app.ErrInternal
+	}
+
+	return added, nil
+}
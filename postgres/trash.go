@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/jmoiron/sqlx"
+)
+
+func (cs *CollectionService) DeletedCollectionByID(ctx context.Context, id int) (*app.Collection, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	collection, err := findOneCollection(ctx, tx, app.CollectionFilter{ID: &id, Deleted: true, IncludeImages: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return collection, tx.Commit()
+}
+
+func (cs *CollectionService) RestoreCollection(ctx context.Context, id int, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, id, version); err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE collections
+	SET deleted_at = NULL
+	WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		if isDuplicateCollectionName(err) {
+			return app.ErrDuplicateName
+		}
+		log.Printf("error updating record: %v", err)
+		return app.ErrInternal
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	} else if n == 0 {
+		return app.ErrNotFound
+	}
+
+	// Sync leaves out the images of trashed collections, so touching them
+	// sends them again.
+	query = `UPDATE collections_images SET change_seq = change_seq WHERE collection_id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		log.Printf("error updating records: %v", err)
+		return app.ErrInternal
+	}
+
+	if err := markCollectionUpdate(ctx, tx, &app.Collection{ID: id}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (cs *CollectionService) PurgeCollection(ctx context.Context, id int, version *int) error {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := lockCollectionVersion(ctx, tx, id, version); err != nil {
+		return err
+	}
+
+	if err := deleteCollection(ctx, tx, &id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
+func (cs *CollectionService) PurgeDeletedCollections(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := cs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return 0, app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	DELETE
+	FROM collections
+	WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	result, err := tx.ExecContext(ctx, query, before)
+	if err != nil {
+		log.Printf("error deleting records: %v", err)
+		return 0, app.ErrInternal
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return 0, app.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return 0, app.ErrInternal
+	}
+
+	return n, nil
+}
+
+func trashCollection(ctx context.Context, tx *sqlx.Tx, id int) error {
+	query := `
+	UPDATE collections
+	SET deleted_at = NOW()
+	WHERE id = $1 AND deleted_at IS NULL`
+
+	if err := execOne(ctx, tx, query, id); err != nil {
+		return err
+	}
+
+	return markCollectionUpdate(ctx, tx, &app.Collection{ID: id})
+}
@@ -162,6 +162,42 @@ func (us *UserService) DeleteUser(ctx context.Context, id uint) error {
 	return nil
 }
 
+func (us *UserService) VerifyUserEmail(ctx context.Context, id uint, email string) error {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE users
+	SET verified_at = COALESCE(verified_at, NOW())
+	WHERE id = $1 AND email = $2`
+
+	res, err := tx.ExecContext(ctx, query, id, email)
+	if err != nil {
+		log.Printf("error updating record: %v", err)
+		return app.ErrInternal
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	} else if n == 0 {
+		return app.ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return app.ErrInternal
+	}
+
+	return nil
+}
+
 func createUser(ctx context.Context, tx *sqlx.Tx, user *app.User) error {
 	query := `
 	INSERT INTO users (email, password_hash)
@@ -228,27 +264,42 @@ func updateUser(ctx context.Context, tx *sqlx.Tx, user *app.User, patch app.User
 		user.Email = *v
 	}
 
+	passwordChanged := false
 	if v := patch.PasswordHash; v != nil {
 		user.PasswordHash = *v
+		passwordChanged = true
 	}
 
 	args := []interface{}{
 		user.Email,
 		user.PasswordHash,
 		user.ID,
+		passwordChanged,
 	}
 
+	// Changing the password bumps the token version so every token issued
+	// with the old password stops working, and a new email address has to be
+	// verified again.
 	query := `
 	UPDATE users 
-	SET email = $1, password_hash = $2, updated_at = NOW()
+	SET email = $1, password_hash = $2, updated_at = NOW(),
+		token_version = token_version + CASE WHEN $4 THEN 1 ELSE 0 END,
+		verified_at = CASE WHEN email = $1 THEN verified_at ELSE NULL END
 	WHERE id = $3
-	RETURNING updated_at`
+	RETURNING updated_at, token_version, verified_at`
 
-	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&user.UpdatedAt); err != nil {
+	if err := tx.QueryRowxContext(ctx, query, args...).Scan(&user.UpdatedAt, &user.TokenVersion, &user.VerifiedAt); err != nil {
 		log.Printf("error updating record: %v", err)
 		return app.ErrInternal
 	}
 
+	if passwordChanged {
+		if err := revokeRefreshTokens(ctx, tx, user.ID); err != nil {
+			log.Printf("error revoking refresh tokens: %v", err)
+			return app.ErrInternal
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+const passwordResetTTL = time.Hour
+
+// sendMail delivers mail in the background so request latency does not
+// depend on the mail server, or reveal whether a mail was sent at all.
+func (s *Server) sendMail(mail app.Mail) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := s.config.Mailer.Send(ctx, mail); err != nil {
+			log.Printf("error sending mail: %v", err)
+		}
+	}()
+}
+
+func (s *Server) appLink(path string, token string) string {
+	return strings.TrimSuffix(s.config.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// issueUserTokens sets a fresh access token and a refresh token starting a
+// new token family on user.
+func (s *Server) issueUserTokens(ctx context.Context, user *app.User) error {
+	token, err := s.generateUserToken(user)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, stored, err := app.NewRefreshToken(user.ID, "", s.config.RefreshTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenService.CreateRefreshToken(ctx, stored); err != nil {
+		return err
+	}
+
+	user.Token = token
+	user.RefreshToken = refreshToken
+
+	return nil
+}
+
+func (s *Server) refreshUserToken() http.HandlerFunc {
+	type Input struct {
+		RefreshToken string `json:"refreshToken" validate:"required"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		ctx := r.Context()
+
+		refreshToken, next, err := app.NewRefreshToken(0, "", s.config.RefreshTokenTTL)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		err = s.tokenService.RotateRefreshToken(ctx, app.HashToken(input.RefreshToken), next)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound),
+				errors.Is(err, app.ErrTokenExpired),
+				errors.Is(err, app.ErrTokenReused):
+				invalidRefreshTokenError(w)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		user, err := s.userService.UserByID(ctx, uint(next.UserID))
+		if err != nil {
+			if errors.Is(err, app.ErrNotFound) {
+				invalidRefreshTokenError(w)
+			} else {
+				serverError(w, err)
+			}
+			return
+		}
+
+		token, err := s.generateUserToken(user)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		user.Token = token
+		user.RefreshToken = refreshToken
+
+		writeJSON(w, http.StatusOK, M{"user": user})
+	}
+}
+
+func (s *Server) logoutUser() http.HandlerFunc {
+	type Input struct {
+		RefreshToken string `json:"refreshToken,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		if r.ContentLength != 0 {
+			shouldReturn := parseInput(r, input, w)
+			if shouldReturn {
+				return
+			}
+		}
+
+		ctx := r.Context()
+		user := userFromContext(ctx)
+		claims := tokenClaimsFromContext(ctx)
+
+		jti, _ := claims["jti"].(string)
+		exp, _ := claims["exp"].(float64)
+
+		if err := s.tokenService.RevokeAccessToken(ctx, user.ID, jti, time.Unix(int64(exp), 0)); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		if input.RefreshToken != "" {
+			if err := s.tokenService.RevokeRefreshToken(ctx, user.ID, app.HashToken(input.RefreshToken)); err != nil {
+				serverError(w, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) logoutUserEverywhere() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := userFromContext(ctx)
+
+		if err := s.tokenService.RevokeUserTokens(ctx, user.ID); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) forgotPassword() http.HandlerFunc {
+	type Input struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		ctx := r.Context()
+		resp := M{"message": "if the account exists, a reset link has been sent"}
+
+		user, err := s.userService.UserByEmail(ctx, input.Email)
+		if err != nil {
+			// Answer the same way for unknown emails so accounts cannot be
+			// enumerated through this endpoint.
+			if !errors.Is(err, app.ErrNotFound) {
+				log.Println(err)
+			}
+			writeJSON(w, http.StatusAccepted, resp)
+			return
+		}
+
+		token, reset, err := app.NewPasswordReset(user.ID, passwordResetTTL)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		if err := s.tokenService.CreatePasswordReset(ctx, reset); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		s.sendMail(app.Mail{
+			To:      user.Email,
+			Subject: "Reset your Posterify password",
+			Body: fmt.Sprintf(
+				"Someone asked to reset the password of your Posterify account.\n\n"+
+					"Follow this link within the next hour to choose a new one:\n%s\n\n"+
+					"If it wasn't you, you can ignore this email.\n",
+				s.appLink("/reset-password", token),
+			),
+		})
+
+		writeJSON(w, http.StatusAccepted, resp)
+	}
+}
+
+func (s *Server) resetPassword() http.HandlerFunc {
+	type Input struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8,max=72"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		ctx := r.Context()
+
+		reset, err := s.tokenService.ConsumePasswordReset(ctx, app.HashToken(input.Token))
+		if err != nil {
+			if errors.Is(err, app.ErrNotFound) {
+				err := ErrorM{"token": []string{"invalid or expired reset token"}}
+				validationError(w, err)
+			} else {
+				serverError(w, err)
+			}
+			return
+		}
+
+		user, err := s.userService.UserByID(ctx, uint(reset.UserID))
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		if err := user.SetPassword(input.Password); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		patch := app.UserPatch{PasswordHash: &user.PasswordHash}
+		if err := s.userService.UpdateUser(ctx, user, patch); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sendVerificationMail mails the user a link that verifies their current
+// email address.
+func (s *Server) sendVerificationMail(user *app.User) error {
+	token, err := s.generateVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(app.Mail{
+		To:      user.Email,
+		Subject: "Verify your Posterify email address",
+		Body: fmt.Sprintf(
+			"Confirm that this is your email address by following this link within the next day:\n%s\n",
+			s.appLink("/verify-email", token),
+		),
+	})
+
+	return nil
+}
+
+func (s *Server) verifyEmail() http.HandlerFunc {
+	type Input struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		invalid := ErrorM{"token": []string{"invalid or expired verification token"}}
+
+		claims, err := s.parseUserToken(input.Token)
+		if err != nil {
+			validationError(w, invalid)
+			return
+		}
+
+		typ, _ := claims["typ"].(string)
+		id, hasID := claims["id"].(float64)
+		email, hasEmail := claims["email"].(string)
+
+		if typ != emailVerificationTokenType || !hasID || !hasEmail {
+			validationError(w, invalid)
+			return
+		}
+
+		if err := s.userService.VerifyUserEmail(r.Context(), uint(id), email); err != nil {
+			if errors.Is(err, app.ErrNotFound) {
+				validationError(w, invalid)
+			} else {
+				serverError(w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) resendVerificationEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+
+		if user.IsVerified() {
+			writeJSON(w, http.StatusOK, M{"message": "email address already verified"})
+			return
+		}
+
+		if err := s.sendVerificationMail(user); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, M{"message": "verification email sent"})
+	}
+}
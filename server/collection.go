@@ -2,20 +2,90 @@ package server
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/Dpalme/posterify-backend/app"
 	"github.com/gorilla/mux"
 )
 
-func collectionBelongsToUser(r *http.Request, collection *app.Collection) bool {
+// collectionRole returns the role the requesting user, who may be
+// anonymous, has on the collection. Public and unlisted collections make
+// everyone at least a viewer.
+func (s *Server) collectionRole(r *http.Request, collection *app.Collection) (app.Role, error) {
 	user := userFromContext(r.Context())
+
+	role := app.RoleNone
+	if collection.IsReadableByAnyone() {
+		role = app.RoleViewer
+	}
+
 	if user.IsAnonymous() {
-		return false
+		return role, nil
+	}
+
+	if user.ID == collection.AuthorID {
+		return app.RoleOwner, nil
+	}
+
+	member, err := s.collectionService.CollectionMember(r.Context(), collection.ID, user.ID)
+	if err != nil {
+		if errors.Is(err, app.ErrNotFound) {
+			return role, nil
+		}
+		return app.RoleNone, err
+	}
+
+	if !member.Role.Can(app.ActionView) {
+		return role, nil
 	}
-	return user.ID == collection.AuthorID
+
+	return member.Role, nil
+}
+
+// authorizedCollection loads the collection named by the id route variable
+// and makes sure the requesting user may perform action on it. On failure
+// it writes the error response and returns nil.
+func (s *Server) authorizedCollection(w http.ResponseWriter, r *http.Request, action app.Action) *app.Collection {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		err := ErrorM{"collection": []string{"id is not valid"}}
+		validationError(w, err)
+		return nil
+	}
+
+	return s.authorizedCollectionByID(w, r, id, action)
+}
+
+// authorizedCollectionByID is authorizedCollection for a collection that is
+// not named by the route.
+func (s *Server) authorizedCollectionByID(w http.ResponseWriter, r *http.Request, id int, action app.Action) *app.Collection {
+	collection, err := s.collectionService.CollectionByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			err := ErrorM{"collection": []string{"collection not found"}}
+			notFoundError(w, err)
+		default:
+			serverError(w, err)
+		}
+		return nil
+	}
+
+	role, err := s.collectionRole(r, collection)
+	if err != nil {
+		serverError(w, err)
+		return nil
+	}
+
+	if !role.Can(action) {
+		unauthorizedForActionError(w)
+		return nil
+	}
+
+	return collection
 }
 
 func (s *Server) createCollection() http.HandlerFunc {
@@ -23,6 +93,7 @@ func (s *Server) createCollection() http.HandlerFunc {
 		Name        string `json:"name" validate:"required,min=3,max=48"`
 		Description string `json:"description" validate:"required,min=0,max=96"`
 		Poster      string `json:"poster,omitempty"`
+		Visibility  string `json:"visibility,omitempty" validate:"omitempty,oneof=public unlisted private"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -39,11 +110,18 @@ func (s *Server) createCollection() http.HandlerFunc {
 			Name:        input.Name,
 			Description: input.Description,
 			Poster:      input.Poster,
+			Visibility:  app.Visibility(input.Visibility),
 			AuthorID:    user.ID,
 		}
 
 		if err := s.collectionService.CreateCollection(r.Context(), &collection); err != nil {
-			serverError(w, err)
+			switch {
+			case errors.Is(err, app.ErrDuplicateName):
+				err := ErrorM{"name": []string{"you already have a collection with this name"}}
+				errorResponse(w, http.StatusConflict, err)
+			default:
+				serverError(w, err)
+			}
 			return
 		}
 
@@ -53,45 +131,27 @@ func (s *Server) createCollection() http.HandlerFunc {
 
 func (s *Server) updateCollection() http.HandlerFunc {
 	type Input struct {
-		ID          *int    `json:"id,omitempty"`
 		Name        *string `json:"name,omitempty"`
 		Description *string `json:"description,omitempty"`
 		Poster      *string `json:"poster,omitempty"`
+		Visibility  *string `json:"visibility,omitempty" validate:"omitempty,oneof=public unlisted private"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		input := &Input{}
-		vars := mux.Vars(r)
 
 		shouldReturn := parseInput(r, input, w)
 		if shouldReturn {
 			return
 		}
 
-		n, err := strconv.ParseInt(vars["id"], 0, 0)
-		if err != nil {
-			err := ErrorM{"collection": []string{"authorId is not valid"}}
-			validationError(w, err)
-			return
-		}
-		nInt := int(n)
-
-		input.ID = &nInt
-
-		if err := validate.Struct(input); err != nil {
-			validationError(w, err)
-			return
-		}
-
 		ctx := r.Context()
-		collection, err := s.collectionService.CollectionByID(ctx, *input.ID)
-		if err != nil {
-			appErr := ErrorM{"collection": []string{"no collection with given id found"}}
-			notFoundError(w, appErr)
+		collection := s.authorizedCollection(w, r, app.ActionUpdate)
+		if collection == nil {
 			return
 		}
 
-		if !collectionBelongsToUser(r, collection) {
-			unauthorizedForActionError(w)
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
 			return
 		}
 
@@ -101,166 +161,489 @@ func (s *Server) updateCollection() http.HandlerFunc {
 			Poster:      input.Poster,
 		}
 
-		err = s.collectionService.UpdateCollection(ctx, collection, patch)
+		if v := input.Visibility; v != nil {
+			visibility := app.Visibility(*v)
+			patch.Visibility = &visibility
+		}
+
+		err := s.collectionService.UpdateCollection(ctx, collection, patch, version)
 		if err != nil {
-			serverError(w, err)
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrDuplicateName):
+				err := ErrorM{"name": []string{"you already have a collection with this name"}}
+				errorResponse(w, http.StatusConflict, err)
+			default:
+				serverError(w, err)
+			}
 			return
 		}
 
+		setCollectionETag(w, collection)
 		writeJSON(w, http.StatusOK, M{"collection": collection})
 	}
 }
 
 func (s *Server) getCollection() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		c_id := vars["id"]
-		ctx := r.Context()
+		collection := s.authorizedCollection(w, r, app.ActionView)
+		if collection == nil {
+			return
+		}
 
-		n, err := strconv.ParseInt(c_id, 0, 0)
-		if err != nil {
-			err := ErrorM{"collection": []string{"authorId is not valid"}}
-			validationError(w, err)
+		setCollectionETag(w, collection)
+		writeJSON(w, http.StatusOK, M{"collection": collection})
+	}
+}
+
+func (s *Server) deleteCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		collection := s.authorizedCollection(w, r, app.ActionDelete)
+		if collection == nil {
 			return
 		}
-		nInt := int(n)
 
-		collection, err := s.collectionService.CollectionByID(ctx, nInt)
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
 
+		err := s.collectionService.DeleteCollection(ctx, collection.ID, version)
 		if err != nil {
 			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
 			case errors.Is(err, app.ErrNotFound):
 				err := ErrorM{"collection": []string{"collection not found"}}
 				notFoundError(w, err)
 			default:
+				err := ErrorM{"collection": []string{"could not delete collection"}}
 				serverError(w, err)
 			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"collection": collection})
+	}
+}
+
+// forkCollection copies a public collection, or one the caller owns, into a
+// new private collection of the caller.
+func (s *Server) forkCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		source := s.authorizedCollection(w, r, app.ActionView)
+		if source == nil {
+			return
+		}
 
+		role, err := s.collectionRole(r, source)
+		if err != nil {
+			serverError(w, err)
 			return
 		}
 
-		if !collectionBelongsToUser(r, collection) {
+		if source.Visibility != app.VisibilityPublic && role != app.RoleOwner {
 			unauthorizedForActionError(w)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, M{"collection": collection})
+		user := userFromContext(ctx)
+		fork, err := s.collectionService.ForkCollection(ctx, source.ID, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"collection": []string{"collection not found"}}
+				notFoundError(w, err)
+			case errors.Is(err, app.ErrDuplicateName):
+				err := ErrorM{"name": []string{"could not pick a free name for the fork, try again"}}
+				errorResponse(w, http.StatusConflict, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, M{"collection": fork})
 	}
 }
 
-func (s *Server) deleteCollection() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		c_id := vars["id"]
+// collectionFilterFromQuery reads the filter and paging parameters shared by
+// the collection listing endpoints.
+func collectionFilterFromQuery(query url.Values) (app.CollectionFilter, error) {
+	filter := app.CollectionFilter{}
+
+	if v := query.Get("name"); v != "" {
+		filter.Name = &v
+	}
+
+	for _, v := range strings.Split(query.Get("include"), ",") {
+		if strings.TrimSpace(v) == "images" {
+			filter.IncludeImages = true
+		}
+	}
+
+	if v := query.Get("id"); v != "" {
+		n, err := strconv.ParseInt(v, 0, 0)
+		if err != nil {
+			return filter, ErrorM{"collection": []string{"id is not valid"}}
+		}
+		nInt := int(n)
+		filter.ID = &nInt
+	}
+
+	// Tags can be repeated (tag=a&tag=b) or comma separated (tag=a,b).
+	tags := []string{}
+	for _, v := range query["tag"] {
+		for _, tag := range strings.Split(v, ",") {
+			if strings.TrimSpace(tag) != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		normalized, err := app.NormalizeTags(tags)
+		if err != nil {
+			return filter, ErrorM{"tag": []string{"tag is not valid"}}
+		}
+		filter.Tags = normalized
+	}
 
+	switch v := app.TagMatch(query.Get("tagMatch")); v {
+	case "", app.TagMatchAll:
+		filter.TagMatch = app.TagMatchAll
+	case app.TagMatchAny:
+		filter.TagMatch = v
+	default:
+		return filter, ErrorM{"tagMatch": []string{"tagMatch must be one of: all any"}}
+	}
+
+	filter.Sort, filter.Order = app.SortByCreated, app.SortAsc
+	if v := app.CollectionSort(query.Get("sort")); v != "" {
+		if !v.IsValid() {
+			return filter, ErrorM{"sort": []string{"sort must be one of: name created updated images"}}
+		}
+		filter.Sort = v
+	}
+	switch v := app.SortOrder(query.Get("order")); v {
+	case "":
+	case app.SortAsc, app.SortDesc:
+		filter.Order = v
+	default:
+		return filter, ErrorM{"order": []string{"order must be one of: asc desc"}}
+	}
+
+	for param, dest := range map[string]**app.Cursor{"after": &filter.After, "before": &filter.Before} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+
+		// A cursor only makes sense in the listing order it came from.
+		cursor, err := app.DecodeCursor(v)
+		if err != nil || cursor.Sort != string(filter.Sort) || cursor.Order != filter.Order {
+			return filter, ErrorM{param: []string{param + " is not a valid cursor for this sort"}}
+		}
+		*dest = cursor
+	}
+
+	if filter.After != nil && filter.Before != nil {
+		return filter, ErrorM{"cursor": []string{"after and before cannot be used together"}}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, ErrorM{"collection": []string{"limit is not valid"}}
+		}
+		uint_limit := int(limit)
+		filter.Limit = uint_limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return filter, ErrorM{"collection": []string{"offset is not valid"}}
+		}
+		uint_offset := int(offset)
+		filter.Offset = uint_offset
+	}
+
+	if filter.Offset > 0 && (filter.After != nil || filter.Before != nil) {
+		return filter, ErrorM{"offset": []string{"offset cannot be used with a cursor"}}
+	}
+
+	return filter, nil
+}
+
+// collectionPageResponse is the envelope of the collection listing
+// endpoints.
+func collectionPageResponse(page *app.CollectionPage, filter app.CollectionFilter) M {
+	var next, prev *string
+	if page.Next != nil {
+		cursor := page.Next.Encode()
+		next = &cursor
+	}
+	if page.Prev != nil {
+		cursor := page.Prev.Encode()
+		prev = &cursor
+	}
+
+	return M{
+		"collections": page.Collections,
+		"total":       page.Total,
+		"next":        next,
+		"prev":        prev,
+		"offset":      filter.Offset,
+		"limit":       filter.Limit,
+	}
+}
+
+func (s *Server) listCollections() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		n, err := strconv.ParseInt(c_id, 0, 0)
+
+		filter, err := collectionFilterFromQuery(r.URL.Query())
 		if err != nil {
-			err := ErrorM{"collection": []string{"authorId is not valid"}}
 			validationError(w, err)
 			return
 		}
-		nInt := int(n)
-		collection, err := s.collectionService.CollectionByID(ctx, nInt)
+
+		user := userFromContext(ctx)
+		filter.MemberID = &user.ID
+
+		page, err := s.collectionService.Collections(ctx, filter)
 
 		if err != nil {
-			if errors.Is(err, app.ErrNotFound) {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
 				err := ErrorM{"collection": []string{"collection not found"}}
 				notFoundError(w, err)
-
-			} else {
+			default:
 				serverError(w, err)
 			}
 
 			return
 		}
 
-		if !collectionBelongsToUser(r, collection) {
-			unauthorizedForActionError(w)
+		writeJSON(w, http.StatusOK, collectionPageResponse(page, filter))
+	}
+}
+
+// listUserCollections lists the public collections of any user.
+func (s *Server) listUserCollections() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			err := ErrorM{"user": []string{"id is not valid"}}
+			validationError(w, err)
 			return
 		}
 
-		err = s.collectionService.DeleteCollection(ctx, nInt)
+		filter, err := collectionFilterFromQuery(r.URL.Query())
+		if err != nil {
+			validationError(w, err)
+			return
+		}
+
+		public := app.VisibilityPublic
+		filter.AuthorId = &authorID
+		filter.Visibility = &public
+
+		page, err := s.collectionService.Collections(ctx, filter)
 		if err != nil {
-			err := ErrorM{"collection": []string{"could not delete collection"}}
 			serverError(w, err)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, M{"collection": collection})
+		writeJSON(w, http.StatusOK, collectionPageResponse(page, filter))
 	}
 }
 
-func (s *Server) listCollections() http.HandlerFunc {
+type saveImageInput struct {
+	ImagePath     *string `json:"imgPath" validate:"omitempty,max=1024"`
+	Caption       string  `json:"caption,omitempty" validate:"max=500"`
+	AltText       string  `json:"altText,omitempty" validate:"max=500"`
+	SourceURL     string  `json:"sourceUrl,omitempty" validate:"omitempty,url,max=2048"`
+	Attribution   string  `json:"attribution,omitempty" validate:"max=255"`
+	Width         int     `json:"width,omitempty" validate:"min=0"`
+	Height        int     `json:"height,omitempty" validate:"min=0"`
+	DominantColor string  `json:"dominantColor,omitempty" validate:"omitempty,hexcolor,max=7"`
+}
+
+func (input *saveImageInput) image() *app.Image {
+	return &app.Image{
+		Path:          *input.ImagePath,
+		Caption:       input.Caption,
+		AltText:       input.AltText,
+		SourceURL:     input.SourceURL,
+		Attribution:   input.Attribution,
+		Width:         input.Width,
+		Height:        input.Height,
+		DominantColor: input.DominantColor,
+	}
+}
+
+func (s *Server) saveImageToCollection() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query()
-		ctx := r.Context()
-		filter := app.CollectionFilter{}
+		input := &saveImageInput{}
 
-		if v := query.Get("name"); v != "" {
-			filter.Name = &v
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
 		}
 
-		user := userFromContext(ctx)
-		filter.AuthorId = &user.ID
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
+		}
 
-		if v := query.Get("id"); v != "" {
-			n, err := strconv.ParseInt(v, 0, 0)
-			if err != nil {
-				err := ErrorM{"collection": []string{"id is not valid"}}
-				validationError(w, err)
-				return
-			}
-			nInt := int(n)
-			filter.ID = &nInt
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		s.saveImage(w, r, collection, input, version)
+	}
+}
+
+// saveImage adds the image described by input to an already authorized
+// collection and writes the updated collection.
+func (s *Server) saveImage(w http.ResponseWriter, r *http.Request, collection *app.Collection, input *saveImageInput, version *int) {
+	if input.ImagePath == nil {
+		err := ErrorM{"collection": []string{"imgPath is not valid"}}
+		validationError(w, err)
+		return
+	}
+
+	image := input.image()
+	if _, err := collection.SaveImageToCollection(image); err != nil {
+		err := ErrorM{"image": []string{"image already in collection"}}
+		errorResponse(w, http.StatusConflict, err)
+		return
+	}
+
+	err := s.collectionService.SaveImageToCollection(r.Context(), collection.ID, image, version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrVersionMismatch):
+			preconditionFailedError(w)
+		case errors.Is(err, app.ErrImageAlreadySaved):
+			err := ErrorM{"image": []string{"image already in collection"}}
+			errorResponse(w, http.StatusConflict, err)
+		case errors.Is(err, app.ErrNotFound):
+			err := ErrorM{"collection": []string{"collection not found"}}
+			notFoundError(w, err)
+		default:
+			serverError(w, err)
+		}
+		return
+	}
+
+	collection, err = s.collectionService.CollectionByID(r.Context(), collection.ID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	setCollectionETag(w, collection)
+	writeJSON(w, http.StatusOK, M{"collection": collection})
+}
+
+// batchUpdateImages saves and removes many images in one atomic change and
+// reports the outcome for each of them.
+func (s *Server) batchUpdateImages() http.HandlerFunc {
+	type Input struct {
+		Add    []*saveImageInput `json:"add" validate:"max=100,dive,required"`
+		Remove []string          `json:"remove" validate:"max=100,dive,required,max=1024"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		if len(input.Add) == 0 && len(input.Remove) == 0 {
+			err := ErrorM{"images": []string{"add or remove at least one image"}}
+			validationError(w, err)
+			return
 		}
 
-		if v := query.Get("limit"); v != "" {
-			limit, err := strconv.ParseUint(v, 10, 32)
-			if err != nil {
-				err := ErrorM{"collection": []string{"limit is not valid"}}
+		batch := app.ImageBatch{Remove: input.Remove}
+		removing := make(map[string]bool, len(input.Remove))
+		for _, path := range input.Remove {
+			removing[path] = true
+		}
+
+		for _, add := range input.Add {
+			if add.ImagePath == nil || *add.ImagePath == "" {
+				err := ErrorM{"add": []string{"imgPath is required for every image"}}
 				validationError(w, err)
 				return
 			}
-			uint_limit := int(limit)
-			filter.Limit = uint_limit
-		}
-		if v := query.Get("offset"); v != "" {
-			offset, err := strconv.ParseUint(v, 10, 32)
-			if err != nil {
-				err := ErrorM{"collection": []string{"offset is not valid"}}
+			if removing[*add.ImagePath] {
+				err := ErrorM{"images": []string{*add.ImagePath + " cannot be added and removed at once"}}
 				validationError(w, err)
 				return
 			}
-			uint_offset := int(offset)
-			filter.Offset = uint_offset
+			batch.Add = append(batch.Add, add.image())
+		}
+
+		ctx := r.Context()
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
 		}
 
-		collection, err := s.collectionService.Collections(ctx, filter)
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
 
+		results, err := s.collectionService.BatchUpdateImages(ctx, collection.ID, batch, version)
 		if err != nil {
 			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
 			case errors.Is(err, app.ErrNotFound):
 				err := ErrorM{"collection": []string{"collection not found"}}
 				notFoundError(w, err)
 			default:
 				serverError(w, err)
 			}
+			return
+		}
 
+		collection, err = s.collectionService.CollectionByID(ctx, collection.ID)
+		if err != nil {
+			serverError(w, err)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, M{"collections": collection, "offset": filter.Offset, "limit": filter.Limit})
+		setCollectionETag(w, collection)
+		writeJSON(w, http.StatusOK, M{"results": results, "collection": collection})
 	}
 }
 
-func (s *Server) saveImageToCollection() http.HandlerFunc {
+func (s *Server) updateCollectionImage() http.HandlerFunc {
 	type Input struct {
-		ImagePath *string `json:"imgPath"`
+		Caption       *string `json:"caption,omitempty" validate:"omitempty,max=500"`
+		AltText       *string `json:"altText,omitempty" validate:"omitempty,max=500"`
+		SourceURL     *string `json:"sourceUrl,omitempty" validate:"omitempty,url,max=2048"`
+		Attribution   *string `json:"attribution,omitempty" validate:"omitempty,max=255"`
+		Width         *int    `json:"width,omitempty" validate:"omitempty,min=0"`
+		Height        *int    `json:"height,omitempty" validate:"omitempty,min=0"`
+		DominantColor *string `json:"dominantColor,omitempty" validate:"omitempty,hexcolor,max=7"`
 	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		ctx := r.Context()
 		input := &Input{}
 
 		shouldReturn := parseInput(r, input, w)
@@ -268,26 +651,33 @@ func (s *Server) saveImageToCollection() http.HandlerFunc {
 			return
 		}
 
-		if input.ImagePath == nil {
-			err := ErrorM{"collection": []string{"imgPath is not valid"}}
-			validationError(w, err)
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
 			return
 		}
 
-		c_id := vars["id"]
-		n, err := strconv.ParseInt(c_id, 0, 0)
-		if err != nil {
-			err := ErrorM{"collection": []string{"authorId is not valid"}}
-			validationError(w, err)
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
 			return
 		}
-		nInt := int(n)
-		collection, err := s.collectionService.CollectionByID(ctx, nInt)
 
+		patch := app.ImagePatch{
+			Caption:       input.Caption,
+			AltText:       input.AltText,
+			SourceURL:     input.SourceURL,
+			Attribution:   input.Attribution,
+			Width:         input.Width,
+			Height:        input.Height,
+			DominantColor: input.DominantColor,
+		}
+
+		image, err := s.collectionService.UpdateImage(r.Context(), collection.ID, mux.Vars(r)["imagePath"], patch, version)
 		if err != nil {
 			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
 			case errors.Is(err, app.ErrNotFound):
-				err := ErrorM{"collection": []string{"collection not found"}}
+				err := ErrorM{"image": []string{"image not in collection"}}
 				notFoundError(w, err)
 			default:
 				serverError(w, err)
@@ -295,61 +685,92 @@ func (s *Server) saveImageToCollection() http.HandlerFunc {
 			return
 		}
 
-		if !collectionBelongsToUser(r, collection) {
-			unauthorizedForActionError(w)
+		writeJSON(w, http.StatusOK, M{"image": image})
+	}
+}
+
+func (s *Server) deleteImageFromCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
 			return
 		}
 
-		fmt.Printf("%+v\n", input)
-
-		err = s.collectionService.SaveImageToCollection(ctx, nInt, *input.ImagePath)
-
-		if err != nil {
-			switch {
-			case errors.Is(err, app.ErrNotFound):
-				err := ErrorM{"collection": []string{"collection not found"}}
-				notFoundError(w, err)
-			default:
-				serverError(w, err)
-			}
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
 			return
 		}
 
-		collection, err = collection.SaveImageToCollection(input.ImagePath)
+		s.removeImage(w, r, collection, mux.Vars(r)["imagePath"], version)
+	}
+}
 
-		if err != nil {
-			switch {
-			case errors.Is(err, app.ErrNotFound):
-				err := ErrorM{"collection": []string{"collection not found"}}
-				notFoundError(w, err)
-			default:
-				serverError(w, err)
-			}
-			return
+// removeImage removes imagePath from an already authorized collection and
+// writes the updated collection.
+func (s *Server) removeImage(w http.ResponseWriter, r *http.Request, collection *app.Collection, imagePath string, version *int) {
+	if _, err := collection.DeleteImageFromCollection(imagePath); err != nil {
+		err := ErrorM{"image": []string{"image not in collection"}}
+		notFoundError(w, err)
+		return
+	}
+
+	if err := s.collectionService.DeleteImageFromCollection(r.Context(), collection.ID, imagePath, version); err != nil {
+		switch {
+		case errors.Is(err, app.ErrVersionMismatch):
+			preconditionFailedError(w)
+		case errors.Is(err, app.ErrNotFound):
+			err := ErrorM{"image": []string{"image not in collection"}}
+			notFoundError(w, err)
+		default:
+			serverError(w, err)
 		}
+		return
+	}
 
-		writeJSON(w, http.StatusOK, M{"collection": collection})
+	collection, err := s.collectionService.CollectionByID(r.Context(), collection.ID)
+	if err != nil {
+		serverError(w, err)
+		return
 	}
+
+	setCollectionETag(w, collection)
+	writeJSON(w, http.StatusOK, M{"collection": collection})
 }
 
-func (s *Server) deleteImageFromCollection() http.HandlerFunc {
+func (s *Server) reorderCollectionImages() http.HandlerFunc {
+	type Input struct {
+		Images []string `json:"images" validate:"required"`
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
 		ctx := r.Context()
-		imagePath := vars["imagePath"]
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
+		}
 
-		c_id := vars["id"]
-		n, err := strconv.ParseInt(c_id, 0, 0)
-		if err != nil {
-			err := ErrorM{"collection": []string{"authorId is not valid"}}
-			validationError(w, err)
+		// The order is built from what the client last loaded, so it must
+		// say which version that was: the same images in a stale order
+		// would otherwise silently win.
+		version, ok := requiredIfMatchVersion(w, r, collection)
+		if !ok {
 			return
 		}
-		nInt := int(n)
-		collection, err := s.collectionService.CollectionByID(ctx, nInt)
 
-		if err != nil {
+		if err := s.collectionService.ReorderImages(ctx, collection.ID, input.Images, version); err != nil {
 			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrStaleImageOrder):
+				err := ErrorM{"images": []string{"images do not match the collection, reload and try again"}}
+				errorResponse(w, http.StatusConflict, err)
 			case errors.Is(err, app.ErrNotFound):
 				err := ErrorM{"collection": []string{"collection not found"}}
 				notFoundError(w, err)
@@ -359,13 +780,13 @@ func (s *Server) deleteImageFromCollection() http.HandlerFunc {
 			return
 		}
 
-		if !collectionBelongsToUser(r, collection) {
-			unauthorizedForActionError(w)
+		collection, err := s.collectionService.CollectionByID(ctx, collection.ID)
+		if err != nil {
+			serverError(w, err)
 			return
 		}
 
-		s.collectionService.DeleteImageFromCollection(ctx, collection.ID, imagePath)
-
+		setCollectionETag(w, collection)
 		writeJSON(w, http.StatusOK, M{"collection": collection})
 	}
 }
@@ -10,8 +10,9 @@ import (
 type contextKey string
 
 const (
-	userKey  contextKey = "user"
-	tokenKey contextKey = "token"
+	userKey   contextKey = "user"
+	tokenKey  contextKey = "token"
+	claimsKey contextKey = "claims"
 )
 
 func setContextUser(r *http.Request, u *app.User) *http.Request {
@@ -43,3 +44,18 @@ func userTokenFromContext(ctx context.Context) string {
 
 	return token
 }
+
+func setContextTokenClaims(r *http.Request, claims M) *http.Request {
+	ctx := context.WithValue(r.Context(), claimsKey, claims)
+	return r.WithContext(ctx)
+}
+
+func tokenClaimsFromContext(ctx context.Context) M {
+	claims, ok := ctx.Value(claimsKey).(M)
+
+	if !ok {
+		return M{}
+	}
+
+	return claims
+}
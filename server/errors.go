@@ -3,7 +3,10 @@ package server
 import (
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -52,6 +55,38 @@ func invalidAuthTokenError(w http.ResponseWriter) {
 	errorResponse(w, http.StatusUnauthorized, msg)
 }
 
+func expiredAuthTokenError(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Token error="invalid_token", error_description="token expired"`)
+	msg := "authentication token has expired"
+	errorResponse(w, http.StatusUnauthorized, msg)
+}
+
+func invalidRefreshTokenError(w http.ResponseWriter) {
+	msg := "invalid or expired refresh token"
+	errorResponse(w, http.StatusUnauthorized, msg)
+}
+
+func unverifiedEmailError(w http.ResponseWriter) {
+	msg := "email address has not been verified"
+	errorResponse(w, http.StatusForbidden, msg)
+}
+
+func tooManyRequestsError(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	msg := "too many requests, try again later"
+	errorResponse(w, http.StatusTooManyRequests, msg)
+}
+
+func preconditionFailedError(w http.ResponseWriter) {
+	msg := "collection was changed by someone else, reload and try again"
+	errorResponse(w, http.StatusPreconditionFailed, msg)
+}
+
+func preconditionRequiredError(w http.ResponseWriter) {
+	msg := "send the collection's ETag in If-Match"
+	errorResponse(w, http.StatusPreconditionRequired, msg)
+}
+
 func notFoundError(w http.ResponseWriter, err ErrorM) {
 	errorResponse(w, http.StatusNotFound, err)
 }
@@ -83,5 +118,17 @@ func checkTagRules(e validator.FieldError) (errMsg string) {
 	if tag == "max" {
 		errMsg = fmt.Sprintf("%s must be less than %v", field, param)
 	}
+
+	if tag == "oneof" {
+		errMsg = fmt.Sprintf("%s must be one of: %v", field, param)
+	}
+
+	if tag == "url" {
+		errMsg = fmt.Sprintf("%q is not a valid url", value)
+	}
+
+	if tag == "hexcolor" {
+		errMsg = fmt.Sprintf("%q is not a valid hex color", value)
+	}
 	return
 }
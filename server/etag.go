@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+func collectionETag(collection *app.Collection) string {
+	return strconv.Quote(strconv.Itoa(collection.Version))
+}
+
+func setCollectionETag(w http.ResponseWriter, collection *app.Collection) {
+	w.Header().Set("ETag", collectionETag(collection))
+}
+
+// ifMatchVersion reads the If-Match header of a write to the collection
+// and returns the version the write expects. Requests without the header,
+// or with "*", expect none. On a mismatch it writes a 412 response and
+// returns false.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request, collection *app.Collection) (*int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil, true
+	}
+
+	etag := collectionETag(collection)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match: If-Match uses strong comparison.
+		switch strings.TrimSpace(tag) {
+		case "*":
+			return nil, true
+		case etag:
+			version := collection.Version
+			return &version, true
+		}
+	}
+
+	preconditionFailedError(w)
+	return nil, false
+}
+
+// requiredIfMatchVersion is ifMatchVersion for writes that must name the
+// version they were made from. "*" names none, and a request naming none
+// gets a 428 response.
+func requiredIfMatchVersion(w http.ResponseWriter, r *http.Request, collection *app.Collection) (*int, bool) {
+	etag := collectionETag(collection)
+	named := false
+	for _, tag := range strings.Split(r.Header.Get("If-Match"), ",") {
+		switch strings.TrimSpace(tag) {
+		case "", "*":
+		case etag:
+			version := collection.Version
+			return &version, true
+		default:
+			named = true
+		}
+	}
+
+	if named {
+		preconditionFailedError(w)
+	} else {
+		preconditionRequiredError(w)
+	}
+	return nil, false
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	collection := &app.Collection{Version: 3}
+
+	tests := []struct {
+		name    string
+		header  string
+		want    *int
+		ok      bool
+		status  int
+		require bool
+	}{
+		{name: "missing", header: "", ok: true},
+		{name: "any", header: "*", ok: true},
+		{name: "current", header: `"3"`, want: &collection.Version, ok: true},
+		{name: "current in list", header: `"2", "3"`, want: &collection.Version, ok: true},
+		{name: "stale", header: `"2"`, status: http.StatusPreconditionFailed},
+		{name: "weak", header: `W/"3"`, status: http.StatusPreconditionFailed},
+		{name: "required missing", header: "", status: http.StatusPreconditionRequired, require: true},
+		{name: "required any", header: "*", status: http.StatusPreconditionRequired, require: true},
+		{name: "required any and current", header: `*, "3"`, want: &collection.Version, ok: true, require: true},
+		{name: "required current", header: `"3"`, want: &collection.Version, ok: true, require: true},
+		{name: "required stale", header: `"2"`, status: http.StatusPreconditionFailed, require: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			check := ifMatchVersion
+			if tt.require {
+				check = requiredIfMatchVersion
+			}
+			got, ok := check(w, r, collection)
+
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("version = %v, want %v", got, tt.want)
+			}
+			if !ok && w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatCSV  = "csv"
+	exportFormatZIP  = "zip"
+)
+
+// exportManifest is the name of the export JSON inside a ZIP archive.
+const exportManifest = "manifest.json"
+
+var exportCSVHeader = []string{
+	"collection", "image", "position", "caption", "altText", "sourceUrl",
+	"attribution", "width", "height", "dominantColor", "tags", "savedAt",
+}
+
+// exportCollection downloads a collection the caller can view.
+func (s *Server) exportCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := exportFormat(w, r)
+		if !ok {
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionView)
+		if collection == nil {
+			return
+		}
+
+		name := fmt.Sprintf("collection-%d", collection.ID)
+		s.writeExport(w, app.NewExport([]*app.Collection{collection}, time.Now()), name, format)
+	}
+}
+
+// exportAccount downloads every collection of the caller outside the trash.
+func (s *Server) exportAccount() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := exportFormat(w, r)
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		user := userFromContext(ctx)
+
+		page, err := s.collectionService.Collections(ctx, app.CollectionFilter{
+			AuthorId:      &user.ID,
+			IncludeImages: true,
+		})
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		s.writeExport(w, app.NewExport(page.Collections, time.Now()), "collections", format)
+	}
+}
+
+func exportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		return exportFormatJSON, true
+	case exportFormatJSON, exportFormatCSV, exportFormatZIP:
+		return format, true
+	}
+
+	err := ErrorM{"format": []string{"format must be json, csv or zip"}}
+	validationError(w, err)
+	return "", false
+}
+
+func (s *Server) writeExport(w http.ResponseWriter, export *app.Export, name string, format string) {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+
+	var err error
+	switch format {
+	case exportFormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		err = writeExportCSV(w, export)
+	case exportFormatZIP:
+		w.Header().Set("Content-Type", "application/zip")
+		err = writeExportZIP(w, export, s.config.ImageDir)
+	default:
+		writeJSON(w, http.StatusOK, export)
+	}
+
+	// Headers are already sent, so the client only sees a cut short file.
+	if err != nil {
+		log.Printf("error writing export: %v", err)
+	}
+}
+
+// writeExportCSV writes a row per image. Collections without images and
+// collection details are left out: only JSON and ZIP exports can be
+// imported again.
+func writeExportCSV(w io.Writer, export *app.Export) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return err
+	}
+
+	for _, c := range export.Collections {
+		for i, image := range c.Images {
+			record := []string{
+				c.Name,
+				image.Path,
+				strconv.Itoa(i),
+				image.Caption,
+				image.AltText,
+				image.SourceURL,
+				image.Attribution,
+				strconv.Itoa(image.Width),
+				strconv.Itoa(image.Height),
+				image.DominantColor,
+				strings.Join(image.Tags, "|"),
+				image.SavedAt.Format(time.RFC3339),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeExportZIP writes the export as the archive's manifest followed by
+// the files of the images stored under imageDir. Each file is added once
+// and every image using it points to it.
+func writeExportZIP(w io.Writer, export *app.Export, imageDir string) error {
+	files := map[string]string{}
+	for _, c := range export.Collections {
+		for _, image := range c.Images {
+			name, ok := localImageFile(imageDir, image.Path)
+			if !ok {
+				continue
+			}
+			image.File = archiveImageName(image.Path)
+			files[image.File] = name
+		}
+	}
+
+	zw := zip.NewWriter(w)
+
+	manifest, err := zw.Create(exportManifest)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(manifest)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	for archived, name := range files {
+		if err := addZIPFile(zw, archived, name); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func addZIPFile(zw *zip.Writer, archived string, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dst, err := zw.Create(archived)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// localImageFile returns the file under imageDir an image path refers to.
+// Images saved by URL, and paths without a file, are not local.
+func localImageFile(imageDir string, imgPath string) (string, bool) {
+	name, ok := imageFileName(imageDir, imgPath)
+	if !ok {
+		return "", false
+	}
+
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	return name, true
+}
+
+// imageFileName returns where under imageDir the file of an image saved by
+// path belongs.
+func imageFileName(imageDir string, imgPath string) (string, bool) {
+	if imageDir == "" {
+		return "", false
+	}
+
+	if u, err := url.Parse(imgPath); err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+
+	// Cleaning the path as an absolute one keeps it inside imageDir.
+	return filepath.Join(imageDir, filepath.FromSlash(path.Clean("/"+imgPath))), true
+}
+
+func archiveImageName(imgPath string) string {
+	return "images" + path.Clean("/"+imgPath)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestImageFileName(t *testing.T) {
+	dir := filepath.FromSlash("/srv/images")
+
+	tests := []struct {
+		name  string
+		dir   string
+		in    string
+		want  string
+		local bool
+	}{
+		{"relative", dir, "film/noir.jpg", "/srv/images/film/noir.jpg", true},
+		{"absolute", dir, "/film/noir.jpg", "/srv/images/film/noir.jpg", true},
+		{"dot segments", dir, "film/./b/../noir.jpg", "/srv/images/film/noir.jpg", true},
+		{"parent", dir, "../etc/passwd", "/srv/images/etc/passwd", true},
+		{"parents past root", dir, "film/../../../etc/passwd", "/srv/images/etc/passwd", true},
+		{"absolute parent", dir, "/../../etc/passwd", "/srv/images/etc/passwd", true},
+		{"no image dir", "", "film/noir.jpg", "", false},
+		{"url", dir, "https://example.com/noir.jpg", "", false},
+		{"file url", dir, "file:///etc/passwd", "", false},
+		{"host only", dir, "//example.com/noir.jpg", "", false},
+		{"bad url", dir, "%zz", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := imageFileName(tt.dir, tt.in)
+			if ok != tt.local {
+				t.Fatalf("imageFileName(%q) ok = %v, want %v", tt.in, ok, tt.local)
+			}
+			if want := filepath.FromSlash(tt.want); got != want {
+				t.Errorf("imageFileName(%q) = %q, want %q", tt.in, got, want)
+			}
+		})
+	}
+}
+
+func TestArchiveImageName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"noir.jpg", "images/noir.jpg"},
+		{"/film/noir.jpg", "images/film/noir.jpg"},
+		{"film/./b/../noir.jpg", "images/film/noir.jpg"},
+		{"../noir.jpg", "images/noir.jpg"},
+		{"../../../etc/passwd", "images/etc/passwd"},
+		{"/../noir.jpg", "images/noir.jpg"},
+		{"film//noir.jpg", "images/film/noir.jpg"},
+	}
+
+	for _, tt := range tests {
+		if got := archiveImageName(tt.in); got != tt.want {
+			t.Errorf("archiveImageName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+const (
+	// maxImportSize caps import uploads, image files of ZIP archives
+	// included, and the manifest once extracted.
+	maxImportSize = 32 << 20
+	// maxImportFilesSize caps the image files of a ZIP archive once
+	// extracted.
+	maxImportFilesSize = 256 << 20
+)
+
+var errImportTooLarge = errors.New("import is too large")
+
+type importImageInput struct {
+	Path          string    `json:"image" validate:"required,max=1024"`
+	Caption       string    `json:"caption" validate:"max=500"`
+	AltText       string    `json:"altText" validate:"max=500"`
+	SourceURL     string    `json:"sourceUrl" validate:"omitempty,url,max=2048"`
+	Attribution   string    `json:"attribution" validate:"max=255"`
+	Width         int       `json:"width" validate:"min=0"`
+	Height        int       `json:"height" validate:"min=0"`
+	DominantColor string    `json:"dominantColor" validate:"omitempty,hexcolor,max=7"`
+	Tags          []string  `json:"tags" validate:"max=20"`
+	SavedAt       time.Time `json:"savedAt"`
+	File          string    `json:"file"`
+}
+
+type importCollectionInput struct {
+	Name        string              `json:"name" validate:"required,max=64"`
+	Description string              `json:"description" validate:"max=255"`
+	Poster      string              `json:"poster" validate:"max=64"`
+	Visibility  string              `json:"visibility" validate:"omitempty,oneof=public unlisted private"`
+	Tags        []string            `json:"tags" validate:"max=20"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	Images      []*importImageInput `json:"images" validate:"max=1000,dive,required"`
+}
+
+// importInput is the export format as written by writeExport.
+type importInput struct {
+	Version     int                      `json:"version" validate:"required"`
+	Collections []*importCollectionInput `json:"collections" validate:"required,min=1,max=100,dive,required"`
+}
+
+// collections converts the input to collections with normalized tags.
+func (input *importInput) collections() ([]*app.Collection, error) {
+	collections := make([]*app.Collection, 0, len(input.Collections))
+	for _, ic := range input.Collections {
+		tags, err := app.NormalizeTags(ic.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("collection %q: %w", ic.Name, err)
+		}
+
+		c := &app.Collection{
+			Name:        ic.Name,
+			Description: ic.Description,
+			Poster:      ic.Poster,
+			Visibility:  app.Visibility(ic.Visibility),
+			Tags:        tags,
+			CreatedAt:   ic.CreatedAt,
+			Images:      make([]*app.Image, 0, len(ic.Images)),
+		}
+
+		for _, ii := range ic.Images {
+			tags, err := app.NormalizeTags(ii.Tags)
+			if err != nil {
+				return nil, fmt.Errorf("image %q of collection %q: %w", ii.Path, ic.Name, err)
+			}
+
+			c.Images = append(c.Images, &app.Image{
+				Path:          ii.Path,
+				Caption:       ii.Caption,
+				AltText:       ii.AltText,
+				SourceURL:     ii.SourceURL,
+				Attribution:   ii.Attribution,
+				Width:         ii.Width,
+				Height:        ii.Height,
+				DominantColor: ii.DominantColor,
+				Tags:          tags,
+				SavedAt:       ii.SavedAt,
+			})
+		}
+
+		collections = append(collections, c)
+	}
+	return collections, nil
+}
+
+// importCollections recreates the collections of a JSON export, or of the
+// manifest of a ZIP export, under the caller. Name conflicts are resolved
+// by the strategy query parameter and dryRun reports the outcome without
+// changing anything.
+func (s *Server) importCollections() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		strategy := app.ImportRename
+		if v := query.Get("strategy"); v != "" {
+			strategy = app.ImportStrategy(v)
+			if !strategy.IsValid() {
+				err := ErrorM{"strategy": []string{"strategy must be rename, skip or merge"}}
+				validationError(w, err)
+				return
+			}
+		}
+
+		dryRun := false
+		if v := query.Get("dryRun"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				err := ErrorM{"dryRun": []string{"dryRun must be true or false"}}
+				validationError(w, err)
+				return
+			}
+			dryRun = b
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			err := ErrorM{"file": []string{"import must be at most 32 MB"}}
+			errorResponse(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+
+		manifest, archive, err := readImport(body)
+		if errors.Is(err, errImportTooLarge) {
+			err := ErrorM{"file": []string{"the manifest must be at most 32 MB"}}
+			errorResponse(w, http.StatusRequestEntityTooLarge, err)
+			return
+		} else if err != nil {
+			err := ErrorM{"file": []string{"file is not a JSON or ZIP export"}}
+			errorResponse(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		input := &importInput{}
+		if err := json.Unmarshal(manifest, input); err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		if err := validate.Struct(input); err != nil {
+			validationError(w, err)
+			return
+		}
+
+		if input.Version > app.ExportVersion {
+			err := ErrorM{"version": []string{"export version " + strconv.Itoa(input.Version) + " is not supported"}}
+			validationError(w, err)
+			return
+		}
+
+		files := map[string]*zip.File{}
+		if archive != nil && s.config.ImageDir != "" {
+			files, err = archivedImageFiles(archive, input)
+			if errors.Is(err, errImportTooLarge) {
+				err := ErrorM{"file": []string{"image files must be at most 256 MB"}}
+				errorResponse(w, http.StatusRequestEntityTooLarge, err)
+				return
+			} else if err != nil {
+				err := ErrorM{"file": []string{"file is not a JSON or ZIP export"}}
+				errorResponse(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+		}
+
+		collections, err := input.collections()
+		if err != nil {
+			err := ErrorM{"tags": []string{err.Error()}}
+			errorResponse(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		// Files are extracted before the import so a committed image always
+		// finds its file once they are moved into place.
+		staged := map[string]string{}
+		if !dryRun && len(files) > 0 {
+			defer removeStagedFiles(staged)
+			if err := stageImageFiles(staged, files, s.config.ImageDir); err != nil {
+				if errors.Is(err, errImportTooLarge) {
+					err := ErrorM{"file": []string{"image files must be at most 256 MB"}}
+					errorResponse(w, http.StatusRequestEntityTooLarge, err)
+				} else {
+					serverError(w, err)
+				}
+				return
+			}
+		}
+
+		ctx := r.Context()
+		user := userFromContext(ctx)
+
+		results, err := s.collectionService.ImportCollections(ctx, app.CollectionImport{
+			AuthorID:    user.ID,
+			Collections: collections,
+			Strategy:    strategy,
+			DryRun:      dryRun,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrDuplicateName):
+				err := ErrorM{"name": []string{"a collection was created with the same name, try again"}}
+				errorResponse(w, http.StatusConflict, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		if dryRun {
+			writeJSON(w, http.StatusOK, M{"results": results, "dryRun": true})
+			return
+		}
+
+		if err := publishImageFiles(staged, results, s.config.ImageDir); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, M{"results": results, "dryRun": false})
+	}
+}
+
+// readImport returns the export JSON of an upload and, for ZIP exports, the
+// archive holding it.
+func readImport(body []byte) ([]byte, *zip.Reader, error) {
+	if !bytes.HasPrefix(body, []byte("PK\x03\x04")) {
+		return body, nil, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range archive.File {
+		if f.Name != exportManifest {
+			continue
+		}
+
+		if f.UncompressedSize64 > maxImportSize {
+			return nil, nil, errImportTooLarge
+		}
+
+		manifest := &bytes.Buffer{}
+		if err := copyZIPFile(manifest, f); err != nil {
+			return nil, nil, err
+		}
+
+		return manifest.Bytes(), archive, nil
+	}
+
+	return nil, nil, os.ErrNotExist
+}
+
+// archivedImageFiles moves the images of input that have a file in the
+// archive to a path named after the file's content, so uploads never pick
+// where files are written and a file is stored once however often it is
+// imported. It returns the archived files by their new image path.
+func archivedImageFiles(archive *zip.Reader, input *importInput) (map[string]*zip.File, error) {
+	entries := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		entries[f.Name] = f
+	}
+
+	archived := func(image *importImageInput) *zip.File {
+		if image.File == "" {
+			return nil
+		}
+		return entries[image.File]
+	}
+
+	// The declared sizes are checked before anything is extracted, and
+	// copyZIPFile holds files to them.
+	var total uint64
+	counted := map[*zip.File]bool{}
+	for _, c := range input.Collections {
+		for _, image := range c.Images {
+			f := archived(image)
+			if f == nil || counted[f] {
+				continue
+			}
+			counted[f] = true
+			total += f.UncompressedSize64
+			if total > maxImportFilesSize {
+				return nil, errImportTooLarge
+			}
+		}
+	}
+
+	files := map[string]*zip.File{}
+	names := map[*zip.File]string{}
+	for _, c := range input.Collections {
+		for _, image := range c.Images {
+			f := archived(image)
+			if f == nil {
+				continue
+			}
+
+			name, ok := names[f]
+			if !ok {
+				h := sha256.New()
+				if err := copyZIPFile(h, f); err != nil {
+					return nil, err
+				}
+				name = "imports/" + hex.EncodeToString(h.Sum(nil)) + imageExt(image.Path)
+				names[f] = name
+			}
+
+			image.Path = name
+			files[name] = f
+		}
+	}
+
+	return files, nil
+}
+
+// imageExt returns the extension of an image path when it looks like one,
+// so stored files keep their type.
+func imageExt(imgPath string) string {
+	ext := strings.ToLower(path.Ext(imgPath))
+	if len(ext) < 2 || len(ext) > 5 {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// copyZIPFile copies an archived file, failing when it holds more than its
+// header declares.
+func copyZIPFile(dst io.Writer, f *zip.File) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	n, err := io.Copy(dst, io.LimitReader(src, int64(f.UncompressedSize64)+1))
+	if err != nil {
+		return err
+	}
+	if uint64(n) > f.UncompressedSize64 {
+		return errImportTooLarge
+	}
+
+	return nil
+}
+
+// stageImageFiles extracts the archived files next to where they belong,
+// under temporary names, and records them in staged by image path. Files
+// already in place have the same content, their name being its hash, and
+// are not extracted again.
+func stageImageFiles(staged map[string]string, files map[string]*zip.File, imageDir string) error {
+	for imgPath, f := range files {
+		name, ok := imageFileName(imageDir, imgPath)
+		if !ok {
+			continue
+		}
+
+		if _, err := os.Stat(name); err == nil {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(name), ".import-*")
+		if err != nil {
+			return err
+		}
+		staged[imgPath] = tmp.Name()
+
+		if err := copyZIPFile(tmp, f); err != nil {
+			tmp.Close()
+			return err
+		}
+
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishImageFiles moves the staged files of the imported images into
+// place. Images that were already saved get theirs too, so a retry mends an
+// import whose files could not be published.
+func publishImageFiles(staged map[string]string, results []*app.ImportResult, imageDir string) error {
+	for _, result := range results {
+		for _, imgPath := range result.ImagePaths {
+			tmp, ok := staged[imgPath]
+			if !ok {
+				continue
+			}
+
+			name, _ := imageFileName(imageDir, imgPath)
+			if err := os.Rename(tmp, name); err != nil {
+				return err
+			}
+			delete(staged, imgPath)
+		}
+	}
+
+	return nil
+}
+
+// removeStagedFiles deletes the staged files that were not published.
+func removeStagedFiles(staged map[string]string) {
+	for _, tmp := range staged {
+		if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("cannot remove %s: %v", tmp, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is a single JWT key identified by its kid header. Keys loaded
+// from a public key can only verify tokens.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func (k *SigningKey) canSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet holds the key used to sign new tokens and every key still accepted
+// when verifying, so keys can be rotated without invalidating live tokens.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewHMACKeySet returns a key set with a single HS256 key.
+func NewHMACKeySet(id string, secret []byte) *KeySet {
+	key := &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+	return &KeySet{active: key, keys: map[string]*SigningKey{id: key}}
+}
+
+// LoadKeySet parses a comma separated list of kid:alg:path entries, where
+// path points to a PEM encoded private or public key (or to the raw secret
+// for HS256), and signs with the key identified by activeID.
+func LoadKeySet(activeID string, spec string) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*SigningKey{}}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid key entry %q, expected kid:alg:path", entry)
+		}
+
+		id, alg, path := parts[0], parts[1], parts[2]
+		if _, ok := ks.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key %q: %w", id, err)
+		}
+
+		key, err := parseSigningKey(id, alg, data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %q: %w", id, err)
+		}
+
+		ks.keys[id] = key
+	}
+
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if !active.canSign() {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+func parseSigningKey(id string, alg string, data []byte) (*SigningKey, error) {
+	key := &SigningKey{ID: id}
+
+	switch alg {
+	case "HS256":
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("HS256 secret must be at least 32 bytes")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey, key.verifyKey = secret, secret
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.signKey, key.verifyKey = private, &private.PublicKey
+		} else if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			key.verifyKey = public
+		} else {
+			return nil, err
+		}
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			edKey := private.(ed25519.PrivateKey)
+			key.signKey, key.verifyKey = edKey, edKey.Public()
+		} else if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+			key.verifyKey = public
+		} else {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	return key, nil
+}
+
+func (ks *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.signKey)
+}
+
+// keyFunc picks the verification key named by the token's kid header and
+// refuses tokens whose algorithm does not match that key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := ks.active
+
+	if kid, ok := token.Header["kid"]; ok {
+		id, ok := kid.(string)
+		if !ok {
+			return nil, app.ErrUnAuthorized
+		}
+
+		if key, ok = ks.keys[id]; !ok {
+			return nil, app.ErrUnAuthorized
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, app.ErrUnAuthorized
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWK is the public part of a signing key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// jwks returns every asymmetric verification key. HMAC secrets are never
+// published.
+func (ks *KeySet) jwks() []JWK {
+	keys := []JWK{}
+
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		keys = append(keys, jwk)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+
+	return keys
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/gorilla/mux"
+)
+
+func memberUserIDFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		err := ErrorM{"member": []string{"userId is not valid"}}
+		validationError(w, err)
+		return 0, false
+	}
+	return userID, true
+}
+
+func (s *Server) listCollectionMembers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection := s.authorizedCollection(w, r, app.ActionView)
+		if collection == nil {
+			return
+		}
+
+		members, err := s.collectionService.CollectionMembers(r.Context(), collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		role, err := s.collectionRole(r, collection)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		// Only those who manage members see their emails.
+		if !role.Can(app.ActionManageMembers) {
+			for _, member := range members {
+				member.Email = ""
+			}
+		}
+
+		writeJSON(w, http.StatusOK, M{"members": members})
+	}
+}
+
+func (s *Server) addCollectionMember() http.HandlerFunc {
+	type Input struct {
+		Email string `json:"email" validate:"required,email"`
+		Role  string `json:"role" validate:"required,oneof=viewer editor owner"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		ctx := r.Context()
+		collection := s.authorizedCollection(w, r, app.ActionManageMembers)
+		if collection == nil {
+			return
+		}
+
+		// Members are added by email, so those who manage them learn whether
+		// an email has an account. The write rate limit keeps that from
+		// scaling to enumeration.
+		invitee, err := s.userService.UserByEmail(ctx, input.Email)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"email": []string{"no user with this email"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		if invitee.ID == collection.AuthorID {
+			err := ErrorM{"email": []string{"the author already owns this collection"}}
+			errorResponse(w, http.StatusConflict, err)
+			return
+		}
+
+		member := &app.CollectionMember{
+			CollectionID: collection.ID,
+			UserID:       invitee.ID,
+			Email:        invitee.Email,
+			Role:         app.Role(input.Role),
+		}
+
+		if err := s.collectionService.AddCollectionMember(ctx, member); err != nil {
+			switch {
+			case errors.Is(err, app.ErrDuplicateMember):
+				err := ErrorM{"email": []string{"this user is already a member"}}
+				errorResponse(w, http.StatusConflict, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, M{"member": member})
+	}
+}
+
+func (s *Server) updateCollectionMember() http.HandlerFunc {
+	type Input struct {
+		Role string `json:"role" validate:"required,oneof=viewer editor owner"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		userID, ok := memberUserIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		collection := s.authorizedCollection(w, r, app.ActionManageMembers)
+		if collection == nil {
+			return
+		}
+
+		member, err := s.collectionService.CollectionMember(ctx, collection.ID, userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"member": []string{"member not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		member.Role = app.Role(input.Role)
+
+		if err := s.collectionService.UpdateCollectionMember(ctx, member); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"member": member})
+	}
+}
+
+func (s *Server) removeCollectionMember() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := memberUserIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		// Members may always leave a collection on their own.
+		action := app.ActionManageMembers
+		if userFromContext(r.Context()).ID == userID {
+			action = app.ActionView
+		}
+
+		collection := s.authorizedCollection(w, r, action)
+		if collection == nil {
+			return
+		}
+
+		if err := s.collectionService.RemoveCollectionMember(r.Context(), collection.ID, userID); err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"member": []string{"member not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -1,9 +1,15 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Dpalme/posterify-backend/app"
 	"github.com/gorilla/handlers"
@@ -15,7 +21,7 @@ func Logger(w io.Writer) func(h http.Handler) http.Handler {
 	})
 }
 
-func (s *Server) authenticate(mustAuth bool) func(http.Handler) http.Handler {
+func (s *Server) authenticate(mode authMode) func(http.Handler) http.Handler {
 
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -23,7 +29,7 @@ func (s *Server) authenticate(mustAuth bool) func(http.Handler) http.Handler {
 			authHeader := r.Header.Get("Authorization")
 
 			if authHeader == "" {
-				if mustAuth {
+				if mode != OptionalAuth {
 					invalidAuthTokenError(w)
 				} else {
 					r = setContextUser(r, &app.AnonymousUser)
@@ -42,24 +48,136 @@ func (s *Server) authenticate(mustAuth bool) func(http.Handler) http.Handler {
 
 			token := ss[1]
 
-			claims, err := parseUserToken(token)
+			claims, err := s.parseUserToken(token)
 
 			if err != nil {
+				if errors.Is(err, app.ErrTokenExpired) {
+					expiredAuthTokenError(w)
+				} else {
+					invalidAuthTokenError(w)
+				}
+				return
+			}
+
+			if typ, _ := claims["typ"].(string); typ != accessTokenType {
 				invalidAuthTokenError(w)
 				return
 			}
 
-			email := claims["email"].(string)
+			id, ok := claims["id"].(float64)
+			version, hasVersion := claims["ver"].(float64)
+			jti, hasJTI := claims["jti"].(string)
 
-			user, err := s.userService.UserByEmail(r.Context(), email)
+			if !ok || !hasVersion || !hasJTI {
+				invalidAuthTokenError(w)
+				return
+			}
+
+			user, err := s.userService.UserByID(r.Context(), uint(id))
+
+			if err != nil {
+				if errors.Is(err, app.ErrNotFound) {
+					invalidAuthTokenError(w)
+				} else {
+					serverError(w, err)
+				}
+				return
+			}
+
+			if int(version) != user.TokenVersion {
+				invalidAuthTokenError(w)
+				return
+			}
+
+			revoked, err := s.tokenService.IsAccessTokenRevoked(r.Context(), jti)
 
 			if err != nil {
 				serverError(w, err)
 				return
 			}
 
+			if revoked {
+				invalidAuthTokenError(w)
+				return
+			}
+
+			if mode == MustAuthVerified && s.config.RequireVerifiedEmail && !user.IsVerified() {
+				unverifiedEmailError(w)
+				return
+			}
+
 			r = setContextUser(r, user)
 			r = setContextUserToken(r, token)
+			r = setContextTokenClaims(r, claims)
+			r = r.WithContext(app.WithActor(r.Context(), user.ID))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+var (
+	authRateLimit  = app.RateLimit{Limit: 10, Period: time.Minute}
+	readRateLimit  = app.RateLimit{Limit: 300, Period: time.Minute}
+	writeRateLimit = app.RateLimit{Limit: 60, Period: time.Minute}
+	// clientRateLimit covers every request of an IP, including those with
+	// tokens that fail to authenticate.
+	clientRateLimit = app.RateLimit{Limit: 600, Period: time.Minute}
+)
+
+// rateLimitPolicy names the bucket group a request is charged to and the
+// limit that applies to it.
+type rateLimitPolicy func(r *http.Request) (string, app.RateLimit)
+
+func authRateLimitPolicy(r *http.Request) (string, app.RateLimit) {
+	return "auth", authRateLimit
+}
+
+// clientRateLimitPolicy runs before authenticate, so its buckets are keyed
+// by client IP and token guessing is throttled too.
+func clientRateLimitPolicy(r *http.Request) (string, app.RateLimit) {
+	return "client", clientRateLimit
+}
+
+func apiRateLimitPolicy(r *http.Request) (string, app.RateLimit) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "read", readRateLimit
+	default:
+		return "write", writeRateLimit
+	}
+}
+
+// rateLimit charges every request to a token bucket keyed by the
+// authenticated user, or the client IP for anonymous callers, and reports
+// the bucket state in RateLimit-* headers.
+func (s *Server) rateLimit(policy rateLimitPolicy) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			group, limit := policy(r)
+
+			key := group + ":ip:" + clientIP(r)
+			if user, ok := r.Context().Value(userKey).(*app.User); ok && !user.IsAnonymous() {
+				key = group + ":user:" + strconv.Itoa(user.ID)
+			}
+
+			result, err := s.config.RateLimiter.Allow(r.Context(), key, limit)
+			if err != nil {
+				// A broken limiter backend should not take the API down with it.
+				log.Printf("rate limiter error: %v", err)
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Limit, int(limit.Period.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.ResetAfter.Seconds()))))
+
+			if !result.Allowed {
+				tooManyRequestsError(w, result.RetryAfter)
+				return
+			}
+
 			h.ServeHTTP(w, r)
 		})
 	}
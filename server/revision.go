@@ -0,0 +1,139 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/gorilla/mux"
+)
+
+const defaultRevisionLimit = 50
+
+func revisionFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	revision, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil || revision < 1 {
+		err := ErrorM{"revision": []string{"rev is not valid"}}
+		validationError(w, err)
+		return 0, false
+	}
+	return revision, true
+}
+
+// listCollectionRevisions lists the history of a collection. History shows
+// who changed what, so it is limited to collaborators who can edit.
+func (s *Server) listCollectionRevisions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		limit, offset := defaultRevisionLimit, 0
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				err := ErrorM{"revision": []string{"limit is not valid"}}
+				validationError(w, err)
+				return
+			}
+			limit = int(n)
+		}
+		if v := query.Get("offset"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				err := ErrorM{"revision": []string{"offset is not valid"}}
+				validationError(w, err)
+				return
+			}
+			offset = int(n)
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
+		}
+
+		revisions, err := s.collectionService.CollectionRevisions(ctx, collection.ID, limit, offset)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"revisions": revisions, "offset": offset, "limit": limit})
+	}
+}
+
+func (s *Server) getCollectionRevision() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revision, ok := revisionFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
+		}
+
+		rev, err := s.collectionService.CollectionRevision(r.Context(), collection.ID, revision)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"revision": []string{"revision not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"revision": rev})
+	}
+}
+
+// restoreCollectionRevision rolls the collection back to a revision. It
+// can change the name and visibility, so only owners may do it.
+func (s *Server) restoreCollectionRevision() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revision, ok := revisionFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		collection := s.authorizedCollection(w, r, app.ActionUpdate)
+		if collection == nil {
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		if err := s.collectionService.RestoreRevision(ctx, collection.ID, revision, version); err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"revision": []string{"revision not found"}}
+				notFoundError(w, err)
+			case errors.Is(err, app.ErrDuplicateName):
+				err := ErrorM{"name": []string{"you already have a collection with the name of this revision"}}
+				errorResponse(w, http.StatusConflict, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		collection, err := s.collectionService.CollectionByID(ctx, collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		setCollectionETag(w, collection)
+		writeJSON(w, http.StatusOK, M{"collection": collection})
+	}
+}
@@ -6,34 +6,96 @@ import (
 	"github.com/rs/cors"
 )
 
+type authMode int
+
 const (
-	MustAuth     = true
-	OptionalAuth = false
+	OptionalAuth authMode = iota
+	MustAuth
+	// MustAuthVerified additionally requires a verified email when the
+	// server is configured to.
+	MustAuthVerified
 )
 
 func (s *Server) routes() {
 	s.router.Use(cors.AllowAll().Handler)
 	s.router.Use(Logger(os.Stdout))
+	s.router.Handle("/.well-known/jwks.json", s.jwks()).Methods("GET")
+
 	apiRouter := s.router.PathPrefix("/api/v1").Subrouter()
+	// Probes come from a few addresses and must never be rate limited.
+	apiRouter.Handle("/health", healthCheck())
 
 	noAuth := apiRouter.PathPrefix("").Subrouter()
+	noAuth.Use(s.rateLimit(authRateLimitPolicy))
 	{
-		noAuth.Handle("/health", healthCheck())
 		noAuth.Handle("/auth/signup", s.createUser()).Methods("POST")
 		noAuth.Handle("/auth/login", s.loginUser()).Methods("POST")
+		noAuth.Handle("/auth/refresh", s.refreshUserToken()).Methods("POST")
+		noAuth.Handle("/auth/password/forgot", s.forgotPassword()).Methods("POST")
+		noAuth.Handle("/auth/password/reset", s.resetPassword()).Methods("POST")
+		noAuth.Handle("/auth/verify", s.verifyEmail()).Methods("POST")
 	}
 
 	authApiRoutes := apiRouter.PathPrefix("").Subrouter()
-	authApiRoutes.Use(s.authenticate(MustAuth))
+	authApiRoutes.Use(s.rateLimit(clientRateLimitPolicy), s.authenticate(MustAuth), s.rateLimit(apiRateLimitPolicy))
 	{
+		authApiRoutes.Handle("/auth/logout", s.logoutUser()).Methods("POST")
+		authApiRoutes.Handle("/auth/logout/all", s.logoutUserEverywhere()).Methods("POST")
+		authApiRoutes.Handle("/auth/verify/resend", s.resendVerificationEmail()).Methods("POST")
 		authApiRoutes.Handle("/user", s.getCurrentUser()).Methods("GET")
 		authApiRoutes.Handle("/user", s.updateUser()).Methods("PUT", "PATCH")
-		authApiRoutes.Handle("/collections", s.createCollection()).Methods("POST")
 		authApiRoutes.Handle("/collections", s.listCollections()).Methods("GET")
-		authApiRoutes.Handle("/collections/{id}", s.getCollection()).Methods("GET")
-		authApiRoutes.Handle("/collections/{id}", s.updateCollection()).Methods("PUT", "PATCH")
-		authApiRoutes.Handle("/collections/{id}", s.deleteCollection()).Methods("DELETE")
-		authApiRoutes.Handle("/collections/{id}/images", s.saveImageToCollection()).Methods("POST")
-		authApiRoutes.Handle("/collections/{id}/images/{imagePath}", s.deleteImageFromCollection()).Methods("DELETE")
+		// Registered before the optional auth routes so trash and export
+		// are not taken for a collection id.
+		authApiRoutes.Handle("/collections/trash", s.listTrash()).Methods("GET")
+		authApiRoutes.Handle("/collections/export", s.exportAccount()).Methods("GET")
+		authApiRoutes.Handle("/collections/{id}/shares", s.listShareLinks()).Methods("GET")
+		authApiRoutes.Handle("/collections/{id}/members", s.listCollectionMembers()).Methods("GET")
+		authApiRoutes.Handle("/collections/{id}/revisions", s.listCollectionRevisions()).Methods("GET")
+		authApiRoutes.Handle("/collections/{id}/revisions/{rev}", s.getCollectionRevision()).Methods("GET")
+		authApiRoutes.Handle("/collections/{id}/members/{userId}", s.removeCollectionMember()).Methods("DELETE")
+		authApiRoutes.Handle("/tags", s.suggestTags()).Methods("GET")
+		authApiRoutes.Handle("/sync", s.syncCollections()).Methods("GET")
+	}
+
+	optionalAuthApiRoutes := apiRouter.PathPrefix("").Subrouter()
+	optionalAuthApiRoutes.Use(s.rateLimit(clientRateLimitPolicy), s.authenticate(OptionalAuth), s.rateLimit(apiRateLimitPolicy))
+	{
+		optionalAuthApiRoutes.Handle("/collections/{id}", s.getCollection()).Methods("GET")
+		optionalAuthApiRoutes.Handle("/collections/{id}/tags", s.listCollectionTags()).Methods("GET")
+		optionalAuthApiRoutes.Handle("/collections/{id}/export", s.exportCollection()).Methods("GET")
+		optionalAuthApiRoutes.Handle("/users/{id}/collections", s.listUserCollections()).Methods("GET")
+		optionalAuthApiRoutes.Handle("/shared/{token}", s.getSharedCollection()).Methods("GET")
+		optionalAuthApiRoutes.Handle("/search", s.searchCollections()).Methods("GET")
+	}
+
+	verifiedApiRoutes := apiRouter.PathPrefix("").Subrouter()
+	verifiedApiRoutes.Use(s.rateLimit(clientRateLimitPolicy), s.authenticate(MustAuthVerified), s.rateLimit(apiRateLimitPolicy))
+	{
+		verifiedApiRoutes.Handle("/collections", s.createCollection()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/import", s.importCollections()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}", s.updateCollection()).Methods("PUT", "PATCH")
+		verifiedApiRoutes.Handle("/collections/{id}", s.deleteCollection()).Methods("DELETE")
+		verifiedApiRoutes.Handle("/collections/{id}/fork", s.forkCollection()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/restore", s.restoreCollection()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/revisions/{rev}/restore", s.restoreCollectionRevision()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/permanent", s.purgeCollection()).Methods("DELETE")
+		verifiedApiRoutes.Handle("/collections/{id}/images", s.saveImageToCollection()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/images/order", s.reorderCollectionImages()).Methods("PUT")
+		verifiedApiRoutes.Handle("/collections/{id}/images/batch", s.batchUpdateImages()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/images/move", s.moveCollectionImages()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/images/copy", s.copyCollectionImages()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/images/{imagePath}", s.updateCollectionImage()).Methods("PATCH")
+		verifiedApiRoutes.Handle("/collections/{id}/images/{imagePath}", s.deleteImageFromCollection()).Methods("DELETE")
+		verifiedApiRoutes.Handle("/collections/{id}/images/{imagePath}/tags", s.addImageTags()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/images/{imagePath}/tags/{tag}", s.removeImageTag()).Methods("DELETE")
+		verifiedApiRoutes.Handle("/collections/{id}/tags", s.addCollectionTags()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/tags/{tag}", s.removeCollectionTag()).Methods("DELETE")
+		verifiedApiRoutes.Handle("/collections/{id}/members", s.addCollectionMember()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/members/{userId}", s.updateCollectionMember()).Methods("PUT", "PATCH")
+		verifiedApiRoutes.Handle("/collections/{id}/shares", s.createShareLink()).Methods("POST")
+		verifiedApiRoutes.Handle("/collections/{id}/shares/{shareId}", s.revokeShareLink()).Methods("DELETE")
+		verifiedApiRoutes.Handle("/shared/{token}/images", s.saveImageToSharedCollection()).Methods("POST")
+		verifiedApiRoutes.Handle("/shared/{token}/images/{imagePath}", s.deleteImageFromSharedCollection()).Methods("DELETE")
 	}
 }
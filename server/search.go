@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchLength    = 256
+)
+
+// searchCollections runs a full-text search over the collections the caller
+// can see.
+func (s *Server) searchCollections() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		sq := app.SearchQuery{
+			Query: strings.TrimSpace(query.Get("q")),
+			Limit: defaultSearchLimit,
+		}
+
+		if sq.Query == "" || len(sq.Query) > maxSearchLength {
+			err := ErrorM{"q": []string{"q must be between 1 and 256 characters"}}
+			validationError(w, err)
+			return
+		}
+
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxSearchLimit {
+				err := ErrorM{"limit": []string{"limit must be between 1 and 50"}}
+				validationError(w, err)
+				return
+			}
+			sq.Limit = n
+		}
+
+		if v := query.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				err := ErrorM{"offset": []string{"offset is not valid"}}
+				validationError(w, err)
+				return
+			}
+			sq.Offset = n
+		}
+
+		if user := userFromContext(ctx); !user.IsAnonymous() {
+			sq.UserID = &user.ID
+		}
+
+		results, total, err := s.collectionService.SearchCollections(ctx, sq)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"results": results, "total": total, "offset": sq.Offset, "limit": sq.Limit})
+	}
+}
@@ -11,15 +11,40 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+type Config struct {
+	Keys            *KeySet
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Mailer          app.Mailer
+	// AppURL is the frontend base URL used to build links sent by mail.
+	AppURL string
+	// RequireVerifiedEmail denies unverified accounts the mutating
+	// collection routes.
+	RequireVerifiedEmail bool
+	RateLimiter          app.RateLimiter
+	// ImageDir holds the image files of images saved by path instead of
+	// URL. ZIP exports bundle the files found there.
+	ImageDir string
+}
+
 type Server struct {
+	config            Config
 	server            *http.Server
 	router            *mux.Router
 	userService       app.UserService
 	collectionService app.CollectionService
+	tokenService      app.TokenService
+	loginAttempts     app.LoginAttemptService
 }
 
-func NewServer(db *postgres.DB) *Server {
+func NewServer(db *postgres.DB, config Config) *Server {
 	s := Server{
+		config: config,
 		server: &http.Server{
 			WriteTimeout: 5 * time.Second,
 			ReadTimeout:  5 * time.Second,
@@ -32,6 +57,8 @@ func NewServer(db *postgres.DB) *Server {
 
 	s.userService = postgres.NewUserService(db)
 	s.collectionService = postgres.NewCollectionService(db)
+	s.tokenService = postgres.NewTokenService(db)
+	s.loginAttempts = postgres.NewLoginAttemptService(db)
 	s.server.Handler = s.router
 
 	return &s
@@ -55,3 +82,10 @@ func healthCheck() http.Handler {
 		writeJSON(rw, http.StatusOK, resp)
 	})
 }
+
+func (s *Server) jwks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, M{"keys": s.config.Keys.jwks()})
+	}
+}
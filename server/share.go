@@ -0,0 +1,182 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/gorilla/mux"
+)
+
+// sharedCollection resolves the token route variable to its collection. On
+// failure it writes the error response and returns nil.
+func (s *Server) sharedCollection(w http.ResponseWriter, r *http.Request) (*app.Collection, *app.ShareLink) {
+	collection, link, err := s.collectionService.CollectionByShareToken(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			err := ErrorM{"share": []string{"share link not found or expired"}}
+			notFoundError(w, err)
+		default:
+			serverError(w, err)
+		}
+		return nil, nil
+	}
+
+	return collection, link
+}
+
+func (s *Server) createShareLink() http.HandlerFunc {
+	type Input struct {
+		Permission string     `json:"permission,omitempty" validate:"omitempty,oneof=read edit"`
+		ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+			err := ErrorM{"expiresAt": []string{"expiresAt must be in the future"}}
+			validationError(w, err)
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionManageMembers)
+		if collection == nil {
+			return
+		}
+
+		permission := app.SharePermissionRead
+		if input.Permission != "" {
+			permission = app.SharePermission(input.Permission)
+		}
+
+		user := userFromContext(r.Context())
+
+		link, err := app.NewShareLink(collection.ID, user.ID, permission, input.ExpiresAt)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		if err := s.collectionService.CreateShareLink(r.Context(), link); err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, M{"shareLink": link})
+	}
+}
+
+func (s *Server) listShareLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection := s.authorizedCollection(w, r, app.ActionManageMembers)
+		if collection == nil {
+			return
+		}
+
+		links, err := s.collectionService.ShareLinks(r.Context(), collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"shareLinks": links})
+	}
+}
+
+func (s *Server) revokeShareLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		linkID, err := strconv.Atoi(mux.Vars(r)["shareId"])
+		if err != nil {
+			err := ErrorM{"share": []string{"id is not valid"}}
+			validationError(w, err)
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionManageMembers)
+		if collection == nil {
+			return
+		}
+
+		if err := s.collectionService.RevokeShareLink(r.Context(), collection.ID, linkID); err != nil {
+			switch {
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"share": []string{"share link not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) getSharedCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection, link := s.sharedCollection(w, r)
+		if collection == nil {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"collection": collection, "permission": link.Permission})
+	}
+}
+
+func (s *Server) saveImageToSharedCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &saveImageInput{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		collection, link := s.sharedCollection(w, r)
+		if collection == nil {
+			return
+		}
+
+		if !link.CanEdit() {
+			unauthorizedForActionError(w)
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		s.saveImage(w, r, collection, input, version)
+	}
+}
+
+func (s *Server) deleteImageFromSharedCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection, link := s.sharedCollection(w, r)
+		if collection == nil {
+			return
+		}
+
+		if !link.CanEdit() {
+			unauthorizedForActionError(w)
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		s.removeImage(w, r, collection, mux.Vars(r)["imagePath"], version)
+	}
+}
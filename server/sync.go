@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+)
+
+// syncCollections returns what changed in the caller's collections, and
+// those shared with them as a member, since the cursor of their last sync.
+// Clients without one get everything.
+func (s *Server) syncCollections() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		since := app.SyncCursor{}
+		if v := query.Get("since"); v != "" {
+			cursor, err := app.DecodeSyncCursor(v)
+			if err != nil {
+				err := ErrorM{"since": []string{"since is not a valid cursor"}}
+				validationError(w, err)
+				return
+			}
+			since = cursor
+		}
+
+		limit := defaultSyncLimit
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxSyncLimit {
+				err := ErrorM{"limit": []string{"limit must be between 1 and 1000"}}
+				validationError(w, err)
+				return
+			}
+			limit = n
+		}
+
+		user := userFromContext(ctx)
+		changes, err := s.collectionService.CollectionChanges(ctx, user.ID, since, limit)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, changes)
+	}
+}
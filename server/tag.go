@@ -0,0 +1,279 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultTagSuggestions = 10
+	maxTagSuggestions     = 50
+)
+
+type tagsInput struct {
+	Tags []string `json:"tags" validate:"required,min=1,max=20"`
+}
+
+// normalizedTags normalizes the tags of input, writing a validation error
+// and returning false when one of them is not valid.
+func normalizedTags(w http.ResponseWriter, input *tagsInput) ([]string, bool) {
+	tags, err := app.NormalizeTags(input.Tags)
+	if err != nil {
+		err := ErrorM{"tags": []string{"tags must be between 1 and 32 characters"}}
+		validationError(w, err)
+		return nil, false
+	}
+	return tags, true
+}
+
+func tagFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tag, err := app.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		err := ErrorM{"tag": []string{"tag is not valid"}}
+		validationError(w, err)
+		return "", false
+	}
+	return tag, true
+}
+
+func (s *Server) listCollectionTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection := s.authorizedCollection(w, r, app.ActionView)
+		if collection == nil {
+			return
+		}
+
+		tags := collection.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+
+		writeJSON(w, http.StatusOK, M{"tags": tags})
+	}
+}
+
+func (s *Server) addCollectionTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &tagsInput{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		tags, ok := normalizedTags(w, input)
+		if !ok {
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionUpdate)
+		if collection == nil {
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		tags, err := s.collectionService.AddCollectionTags(r.Context(), collection.ID, tags, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"collection": []string{"collection not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		collection, err = s.collectionService.CollectionByID(r.Context(), collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		setCollectionETag(w, collection)
+		writeJSON(w, http.StatusOK, M{"tags": tags})
+	}
+}
+
+func (s *Server) removeCollectionTag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag, ok := tagFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionUpdate)
+		if collection == nil {
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		err := s.collectionService.RemoveCollectionTag(r.Context(), collection.ID, tag, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"tag": []string{"collection is not tagged with " + tag}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		collection, err = s.collectionService.CollectionByID(r.Context(), collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		setCollectionETag(w, collection)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) addImageTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &tagsInput{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		tags, ok := normalizedTags(w, input)
+		if !ok {
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		tags, err := s.collectionService.AddImageTags(r.Context(), collection.ID, mux.Vars(r)["imagePath"], tags, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"image": []string{"image not in collection"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		collection, err = s.collectionService.CollectionByID(r.Context(), collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		setCollectionETag(w, collection)
+		writeJSON(w, http.StatusOK, M{"tags": tags})
+	}
+}
+
+func (s *Server) removeImageTag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag, ok := tagFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		collection := s.authorizedCollection(w, r, app.ActionEditImages)
+		if collection == nil {
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		err := s.collectionService.RemoveImageTag(r.Context(), collection.ID, mux.Vars(r)["imagePath"], tag, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"tag": []string{"image is not tagged with " + tag}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		collection, err = s.collectionService.CollectionByID(r.Context(), collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		setCollectionETag(w, collection)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// suggestTags autocompletes the tags used on the caller's collections.
+func (s *Server) suggestTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		// An empty prefix lists the most used tags.
+		prefix := ""
+		if v := query.Get("prefix"); v != "" {
+			tag, err := app.NormalizeTag(v)
+			if err != nil {
+				err := ErrorM{"prefix": []string{"prefix is not valid"}}
+				validationError(w, err)
+				return
+			}
+			prefix = tag
+		}
+
+		limit := defaultTagSuggestions
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxTagSuggestions {
+				err := ErrorM{"limit": []string{"limit must be between 1 and 50"}}
+				validationError(w, err)
+				return
+			}
+			limit = n
+		}
+
+		user := userFromContext(ctx)
+		tags, err := s.collectionService.TagSuggestions(ctx, user.ID, prefix, limit)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"tags": tags})
+	}
+}
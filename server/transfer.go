@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dpalme/posterify-backend/app"
+)
+
+func (s *Server) moveCollectionImages() http.HandlerFunc {
+	return s.transferImages(true)
+}
+
+func (s *Server) copyCollectionImages() http.HandlerFunc {
+	return s.transferImages(false)
+}
+
+// transferImages copies images to other collections, removing them from
+// this one when move is set. Copying only needs read access to the source,
+// moving needs to edit it. Every target must be editable by the caller.
+func (s *Server) transferImages(move bool) http.HandlerFunc {
+	type Input struct {
+		Targets []int    `json:"targets" validate:"required,min=1,max=20"`
+		Images  []string `json:"images" validate:"required,min=1,max=100,dive,required,max=1024"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		input := &Input{}
+
+		shouldReturn := parseInput(r, input, w)
+		if shouldReturn {
+			return
+		}
+
+		action := app.ActionView
+		if move {
+			action = app.ActionEditImages
+		}
+
+		source := s.authorizedCollection(w, r, action)
+		if source == nil {
+			return
+		}
+
+		transfer := app.ImageTransfer{SourceID: source.ID, Paths: input.Images, Move: move}
+		if move {
+			version, ok := ifMatchVersion(w, r, source)
+			if !ok {
+				return
+			}
+			transfer.SourceVersion = version
+		}
+
+		seen := map[int]bool{}
+		for _, id := range input.Targets {
+			if id == source.ID {
+				err := ErrorM{"targets": []string{"targets cannot include the source collection"}}
+				validationError(w, err)
+				return
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if target := s.authorizedCollectionByID(w, r, id, app.ActionEditImages); target == nil {
+				return
+			}
+			transfer.TargetIDs = append(transfer.TargetIDs, id)
+		}
+
+		results, err := s.collectionService.TransferImages(r.Context(), transfer)
+		if err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"collection": []string{"collection not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		writeJSON(w, http.StatusOK, M{"results": results})
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dpalme/posterify-backend/app"
+	"github.com/gorilla/mux"
+)
+
+// trashedCollection loads the trashed collection named by the id route
+// variable and makes sure the requesting user may delete it. On failure it
+// writes the error response and returns nil.
+func (s *Server) trashedCollection(w http.ResponseWriter, r *http.Request) *app.Collection {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		err := ErrorM{"collection": []string{"id is not valid"}}
+		validationError(w, err)
+		return nil
+	}
+
+	collection, err := s.collectionService.DeletedCollectionByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrNotFound):
+			err := ErrorM{"collection": []string{"collection not in trash"}}
+			notFoundError(w, err)
+		default:
+			serverError(w, err)
+		}
+		return nil
+	}
+
+	role, err := s.collectionRole(r, collection)
+	if err != nil {
+		serverError(w, err)
+		return nil
+	}
+
+	if !role.Can(app.ActionDelete) {
+		unauthorizedForActionError(w)
+		return nil
+	}
+
+	return collection
+}
+
+// listTrash lists the caller's collections in the trash.
+func (s *Server) listTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		filter, err := collectionFilterFromQuery(r.URL.Query())
+		if err != nil {
+			validationError(w, err)
+			return
+		}
+
+		user := userFromContext(ctx)
+		filter.AuthorId = &user.ID
+		filter.Deleted = true
+
+		page, err := s.collectionService.Collections(ctx, filter)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, collectionPageResponse(page, filter))
+	}
+}
+
+func (s *Server) restoreCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		collection := s.trashedCollection(w, r)
+		if collection == nil {
+			return
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		if err := s.collectionService.RestoreCollection(ctx, collection.ID, version); err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrDuplicateName):
+				err := ErrorM{"name": []string{"you already have a collection with this name, rename it first"}}
+				errorResponse(w, http.StatusConflict, err)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"collection": []string{"collection not in trash"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		collection, err := s.collectionService.CollectionByID(ctx, collection.ID)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		setCollectionETag(w, collection)
+		writeJSON(w, http.StatusOK, M{"collection": collection})
+	}
+}
+
+// purgeCollection deletes a collection for good, skipping the trash.
+func (s *Server) purgeCollection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			err := ErrorM{"collection": []string{"id is not valid"}}
+			validationError(w, err)
+			return
+		}
+
+		collection, err := s.collectionService.CollectionByID(ctx, id)
+		if errors.Is(err, app.ErrNotFound) {
+			collection = s.trashedCollection(w, r)
+			if collection == nil {
+				return
+			}
+		} else if err != nil {
+			serverError(w, err)
+			return
+		} else {
+			role, err := s.collectionRole(r, collection)
+			if err != nil {
+				serverError(w, err)
+				return
+			}
+
+			if !role.Can(app.ActionDelete) {
+				unauthorizedForActionError(w)
+				return
+			}
+		}
+
+		version, ok := ifMatchVersion(w, r, collection)
+		if !ok {
+			return
+		}
+
+		if err := s.collectionService.PurgeCollection(ctx, collection.ID, version); err != nil {
+			switch {
+			case errors.Is(err, app.ErrVersionMismatch):
+				preconditionFailedError(w)
+			case errors.Is(err, app.ErrNotFound):
+				err := ErrorM{"collection": []string{"collection not found"}}
+				notFoundError(w, err)
+			default:
+				serverError(w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
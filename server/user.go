@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/Dpalme/posterify-backend/app"
 	"github.com/go-playground/validator/v10"
@@ -14,6 +15,21 @@ import (
 
 var validate *validator.Validate
 
+var (
+	accountLockoutPolicy = app.LockoutPolicy{
+		Threshold: 5,
+		BaseDelay: 30 * time.Second,
+		MaxDelay:  time.Hour,
+		Window:    24 * time.Hour,
+	}
+	ipLockoutPolicy = app.LockoutPolicy{
+		Threshold: 20,
+		BaseDelay: time.Minute,
+		MaxDelay:  time.Hour,
+		Window:    time.Hour,
+	}
+)
+
 func init() {
 	validate = validator.New()
 	validate.RegisterTagNameFunc(func(fid reflect.StructField) string {
@@ -61,6 +77,10 @@ func (s *Server) createUser() http.HandlerFunc {
 			return
 		}
 
+		if err := s.sendVerificationMail(&user); err != nil {
+			log.Printf("error sending verification email: %v", err)
+		}
+
 		writeJSON(w, http.StatusCreated, M{"user": user})
 	}
 }
@@ -77,23 +97,52 @@ func (s *Server) loginUser() http.HandlerFunc {
 			errorResponse(w, http.StatusUnprocessableEntity, err)
 			return
 		}
-		log.Printf("Authenticating user %v %v", input.Email, input.Password)
 
-		user, err := s.userService.Authenticate(r.Context(), input.Email, input.Password)
+		ctx := r.Context()
+		accountKey := "account:" + strings.ToLower(strings.TrimSpace(input.Email))
+		ipKey := "ip:" + clientIP(r)
+
+		// Refuse locked out callers before paying for a bcrypt comparison.
+		lockedUntil, err := s.loginAttempts.LockedUntil(ctx, accountKey, ipKey)
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+
+		if wait := time.Until(lockedUntil); wait > 0 {
+			tooManyRequestsError(w, wait)
+			return
+		}
+
+		user, err := s.userService.Authenticate(ctx, input.Email, input.Password)
 		if err != nil || user == nil {
+			if err != nil && !errors.Is(err, app.ErrNotFound) && !errors.Is(err, app.ErrUnAuthorized) {
+				serverError(w, err)
+				return
+			}
+
+			for key, policy := range map[string]app.LockoutPolicy{
+				accountKey: accountLockoutPolicy,
+				ipKey:      ipLockoutPolicy,
+			} {
+				if _, err := s.loginAttempts.RecordLoginFailure(ctx, key, policy); err != nil {
+					log.Printf("error recording login failure: %v", err)
+				}
+			}
+
 			invalidUserCredentialsError(w)
 			return
 		}
 
-		token, err := generateUserToken(user)
+		if err := s.loginAttempts.ResetLoginFailures(ctx, accountKey); err != nil {
+			log.Printf("error resetting login failures: %v", err)
+		}
 
-		if err != nil {
+		if err := s.issueUserTokens(ctx, user); err != nil {
 			serverError(w, err)
 			return
 		}
 
-		user.Token = token
-
 		writeJSON(w, http.StatusOK, M{"user": user})
 
 	}
@@ -111,8 +160,8 @@ func (s *Server) getCurrentUser() http.HandlerFunc {
 
 func (s *Server) updateUser() http.HandlerFunc {
 	type Input struct {
-		Email    *string `json:"email,omitempty"`
-		Password *string `json:"password,omitempty"`
+		Email    *string `json:"email,omitempty" validate:"omitempty,email"`
+		Password *string `json:"password,omitempty" validate:"omitempty,min=8,max=72"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		input := &Input{}
@@ -134,16 +183,37 @@ func (s *Server) updateUser() http.HandlerFunc {
 		}
 
 		if v := input.Password; v != nil {
-			user.SetPassword(*v)
+			if err := user.SetPassword(*v); err != nil {
+				serverError(w, err)
+				return
+			}
+			patch.PasswordHash = &user.PasswordHash
 		}
 
+		previousEmail := user.Email
+
 		err := s.userService.UpdateUser(ctx, user, patch)
 		if err != nil {
 			serverError(w, err)
 			return
 		}
 
-		user.Token = userTokenFromContext(ctx)
+		if user.Email != previousEmail {
+			if err := s.sendVerificationMail(user); err != nil {
+				log.Printf("error sending verification email: %v", err)
+			}
+		}
+
+		// A password change revokes every existing token, including the one
+		// used for this request, so hand the caller a fresh pair.
+		if patch.PasswordHash != nil {
+			if err := s.issueUserTokens(ctx, user); err != nil {
+				serverError(w, err)
+				return
+			}
+		} else {
+			user.Token = userTokenFromContext(ctx)
+		}
 
 		writeJSON(w, http.StatusOK, M{"user": user})
 	}
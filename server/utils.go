@@ -1,10 +1,15 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/Dpalme/posterify-backend/app"
 	"github.com/golang-jwt/jwt"
@@ -30,37 +35,64 @@ func writeJSON(w http.ResponseWriter, code int, data interface{}) {
 	}
 }
 
+// clientIP returns the address of the peer that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func readJSON(body io.Reader, input interface{}) error {
 	return json.NewDecoder(body).Decode(input)
 }
 
-var hmacSampleSecret = []byte("sample-secret")
-
-func generateUserToken(user *app.User) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":    user.ID,
-		"email": user.Email,
-	})
-
-	tokenString, err := token.SignedString(hmacSampleSecret)
+const (
+	accessTokenType            = "access"
+	emailVerificationTokenType = "email_verification"
+	emailVerificationTTL       = 24 * time.Hour
+)
 
-	if err != nil {
+func (s *Server) generateUserToken(user *app.User) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	now := time.Now()
+	return s.config.Keys.sign(jwt.MapClaims{
+		"id":    user.ID,
+		"email": user.Email,
+		"iat":   now.Unix(),
+		"exp":   now.Add(s.config.AccessTokenTTL).Unix(),
+		"jti":   hex.EncodeToString(jti),
+		"ver":   user.TokenVersion,
+		"typ":   accessTokenType,
+	})
 }
 
-func parseUserToken(tokenStr string) (userClaims M, err error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, app.ErrUnAuthorized
-		}
-
-		return hmacSampleSecret, nil
+// generateVerificationToken signs a token proving ownership of the user's
+// current email address.
+func (s *Server) generateVerificationToken(user *app.User) (string, error) {
+	now := time.Now()
+	return s.config.Keys.sign(jwt.MapClaims{
+		"id":    user.ID,
+		"email": user.Email,
+		"iat":   now.Unix(),
+		"exp":   now.Add(emailVerificationTTL).Unix(),
+		"typ":   emailVerificationTokenType,
 	})
+}
+
+func (s *Server) parseUserToken(tokenStr string) (userClaims M, err error) {
+	token, err := jwt.Parse(tokenStr, s.config.Keys.keyFunc)
 
 	if err != nil {
+		var vErr *jwt.ValidationError
+		if errors.As(err, &vErr) && vErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, app.ErrTokenExpired
+		}
 		return nil, err
 	}
 